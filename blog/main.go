@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"log"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	geeError "github.com/gee-coder/gee/error"
 	geeLog "github.com/gee-coder/gee/log"
 	geePool "github.com/gee-coder/gee/pool"
+	"github.com/gee-coder/gee/render"
 	"github.com/gee-coder/gee/token"
 )
 
@@ -107,6 +110,14 @@ func main() {
 	// 待办：为特定的中间件 需要指定不进行拦截的请求
 	engine.AddMiddlewareFunc(jh.AuthInterceptor)
 
+	// 页面中的脚本需要带上nonce <script nonce="{{ cspNonce }}">
+	engine.AddMiddlewareFunc(gee.Secure(gee.SecureOptions{
+		ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-{nonce}'",
+		HSTSMaxAge:            180 * 24 * time.Hour,
+	}))
+	// 登录表单使用 模板中通过{{ csrfField }}输出token
+	csrf := gee.CSRF(gee.CSRFOptions{})
+
 	group := engine.Group("user")
 	group.AddMiddlewareFunc(func(next gee.HandlerFunc) gee.HandlerFunc {
 		return func(ctx *gee.Context) {
@@ -200,16 +211,27 @@ func main() {
 			Name: "牛牛",
 		}
 		ctx.HTMLTemplate("login.html", template.FuncMap{}, user, "tpl/login.html", "tpl/header.html")
-	})
+	}, csrf)
 	group.Get("/login", func(ctx *gee.Context) {
 		fmt.Println("handler")
 		user := &User{
 			Name: "牛牛",
 		}
 		ctx.HTMLTemplateGlob("login.html", template.FuncMap{}, user, "tpl/*.html")
-	})
+	}, csrf)
 	// 预加载
 	engine.LoadTemplate("tpl/*.html")
+	// 模板目录下的静态文件 /assets/index.html
+	engine.Group("assets").Static("", "tpl", gee.StaticOptions{MaxAge: time.Hour})
+	// 布局tpl/layouts/base.html 页面中定义title和content 开发模式下修改模板不用重启
+	engine.SetTemplateManager(&render.TemplateManager{
+		Roots:    []fs.FS{os.DirFS("tpl")},
+		Partials: []string{"header.html"},
+		Debug:    true,
+	})
+	group.Get("/home", func(ctx *gee.Context) {
+		ctx.HTMLLayout(http.StatusOK, "base.html", "home.html", &User{Name: "牛牛"})
+	})
 	engine.SetFuncMap(template.FuncMap{})
 	group.Get("/login2", func(ctx *gee.Context) {
 		fmt.Println("handler")
@@ -220,7 +242,7 @@ func main() {
 		if err != nil {
 			log.Println(err)
 		}
-	})
+	}, csrf)
 
 	// 重定向页面
 	group.Get("/redirect", func(ctx *gee.Context) {
@@ -269,7 +291,7 @@ func main() {
 			ctx.SaveUploadedFile(file, "./upload/"+file.Filename)
 		}
 		ctx.JSON(http.StatusOK, m)
-	})
+	}, csrf)
 
 	// json
 	group.Post("/jsonParam", func(ctx *gee.Context) {
@@ -347,7 +369,7 @@ func main() {
 		case *BlogResponse:
 			return http.StatusOK, e.Response()
 		default:
-			return http.StatusInternalServerError, "Internal Server Error"
+			return gee.DefaultErrorHandler(err)
 		}
 	})
 
@@ -434,5 +456,7 @@ func main() {
 	})
 
 	// engine.Run()
-	engine.RunTLS(":8118", "key/server.pem", "key/server.key")
+	if err := engine.RunTLS(":8118", "key/server.pem", "key/server.key"); err != nil {
+		log.Fatal(err)
+	}
 }
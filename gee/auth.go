@@ -35,11 +35,12 @@ func (a *Accounts) BasicAuth(next HandlerFunc) HandlerFunc {
 }
 
 func (a *Accounts) unAuthHandler(ctx *Context) {
+	ctx.Abort()
 	if a.UnAuthHandler != nil {
 		a.UnAuthHandler(ctx)
 	} else {
 		ctx.W.Header().Set("WWW-Authenticate", a.Realm)
-		ctx.W.WriteHeader(http.StatusUnauthorized)
+		ctx.Error(NewProblem(http.StatusUnauthorized, ""))
 	}
 }
 
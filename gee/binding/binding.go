@@ -2,12 +2,62 @@ package binding
 
 import "net/http"
 
+const (
+	MIMEJSON              = "application/json"
+	MIMEHTML              = "text/html"
+	MIMEXML               = "application/xml"
+	MIMEXML2              = "text/xml"
+	MIMEPlain             = "text/plain"
+	MIMEPOSTForm          = "application/x-www-form-urlencoded"
+	MIMEMultipartPOSTForm = "multipart/form-data"
+	MIMEPROTOBUF          = "application/x-protobuf"
+	MIMEYAML              = "application/x-yaml"
+	MIMEYAML2             = "application/yaml"
+	MIMEMSGPACK           = "application/x-msgpack"
+	MIMEMSGPACK2          = "application/msgpack"
+)
+
 type Binding interface {
 	Name() string
 	Bind(*http.Request, any) error
 }
 
+// BindingUri 绑定路由参数 /user/:id
+type BindingUri interface {
+	Name() string
+	BindUri(map[string][]string, any) error
+}
+
 var (
-	JSON = jsonBinding{}
-	XML  = xmlBinding{}
+	JSON      = jsonBinding{}
+	XML       = xmlBinding{}
+	Form      = formBinding{}
+	Query     = queryBinding{}
+	Header    = headerBinding{}
+	Multipart = multipartBinding{}
+	ProtoBuf  = protobufBinding{}
+	YAML      = yamlBinding{}
+	Uri       = uriBinding{}
 )
+
+// Default 根据请求方法和Content-Type选择绑定方式
+// GET请求绑定查询参数 其他请求按Content-Type选择 未知类型按表单处理
+func Default(method, contentType string) Binding {
+	if method == http.MethodGet {
+		return Form
+	}
+	switch contentType {
+	case MIMEJSON:
+		return JSON
+	case MIMEXML, MIMEXML2:
+		return XML
+	case MIMEPROTOBUF:
+		return ProtoBuf
+	case MIMEYAML, MIMEYAML2:
+		return YAML
+	case MIMEMultipartPOSTForm:
+		return Multipart
+	default:
+		return Form
+	}
+}
@@ -0,0 +1,94 @@
+package binding
+
+import (
+	"errors"
+	"net/http"
+	"net/textproto"
+)
+
+// 32M
+const defaultMemory = 32 << 20
+
+// 绑定查询参数和表单 字段使用 form:"name" 标签
+type formBinding struct{}
+
+func (formBinding) Name() string {
+	return "form"
+}
+
+func (formBinding) Bind(r *http.Request, obj any) error {
+	// 同时解析查询参数和表单 不是multipart表单时按普通表单解析
+	if err := r.ParseMultipartForm(defaultMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return err
+	}
+	if err := mapForm(obj, valuesSource(r.Form), "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// 只绑定查询参数
+type queryBinding struct{}
+
+func (queryBinding) Name() string {
+	return "query"
+}
+
+func (queryBinding) Bind(r *http.Request, obj any) error {
+	if err := mapForm(obj, valuesSource(r.URL.Query()), "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// 绑定请求头 字段使用 header:"X-Token" 标签
+type headerBinding struct{}
+
+func (headerBinding) Name() string {
+	return "header"
+}
+
+func (headerBinding) Bind(r *http.Request, obj any) error {
+	source := func(name string) ([]string, bool) {
+		values, ok := r.Header[textproto.CanonicalMIMEHeaderKey(name)]
+		return values, ok
+	}
+	if err := mapForm(obj, source, "header"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// 绑定multipart表单 *multipart.FileHeader和[]*multipart.FileHeader类型的字段绑定上传的文件
+type multipartBinding struct{}
+
+func (multipartBinding) Name() string {
+	return "multipart/form-data"
+}
+
+func (multipartBinding) Bind(r *http.Request, obj any) error {
+	if err := r.ParseMultipartForm(defaultMemory); err != nil {
+		return err
+	}
+	if err := mapForm(obj, valuesSource(r.MultipartForm.Value), "form"); err != nil {
+		return err
+	}
+	if err := mapFiles(obj, r.MultipartForm.File, "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// 绑定路由参数 字段使用 uri:"id" 标签
+type uriBinding struct{}
+
+func (uriBinding) Name() string {
+	return "uri"
+}
+
+func (uriBinding) BindUri(params map[string][]string, obj any) error {
+	if err := mapForm(obj, valuesSource(params), "uri"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
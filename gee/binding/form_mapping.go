@@ -0,0 +1,255 @@
+package binding
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 按名称取值
+type source func(name string) ([]string, bool)
+
+func valuesSource(values map[string][]string) source {
+	return func(name string) ([]string, bool) {
+		v, ok := values[name]
+		return v, ok
+	}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+	unmarshalType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// 把取到的值按标签写入结构体
+// 没有标签的字段使用字段名 标签为 - 的字段跳过
+// 值不存在时使用 default:"1" 标签中的默认值 切片的默认值用逗号分隔
+// 时间字段可以用 time_format:"2006-01-02" 指定格式 默认RFC3339
+func mapForm(obj any, src source, tag string) error {
+	value, err := structValue(obj)
+	if err != nil {
+		return err
+	}
+	return mapStruct(value, src, tag)
+}
+
+func structValue(obj any) (reflect.Value, error) {
+	value := reflect.ValueOf(obj)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return reflect.Value{}, errors.New("binding element must be a non-nil pointer")
+	}
+	value = value.Elem()
+	if value.Kind() != reflect.Struct {
+		return reflect.Value{}, errors.New("binding element must be a pointer to struct")
+	}
+	return value, nil
+}
+
+func mapStruct(value reflect.Value, src source, tag string) error {
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" || field.Type == fileHeaderType || field.Type == reflect.SliceOf(fileHeaderType) {
+			continue
+		}
+		fieldValue := value.Field(i)
+		// 没有标签的嵌套结构体 展开绑定
+		if name == "" && isNestedStruct(field.Type) {
+			if fieldValue.Kind() == reflect.Pointer {
+				if fieldValue.IsNil() {
+					fieldValue.Set(reflect.New(field.Type.Elem()))
+				}
+				fieldValue = fieldValue.Elem()
+			}
+			if err := mapStruct(fieldValue, src, tag); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		values, ok := src(name)
+		if !ok {
+			def, hasDefault := field.Tag.Lookup("default")
+			if !hasDefault {
+				continue
+			}
+			values = []string{def}
+			if field.Type.Kind() == reflect.Slice || field.Type.Kind() == reflect.Array {
+				values = strings.Split(def, ",")
+			}
+		}
+		if err := setField(fieldValue, field, values); err != nil {
+			return fmt.Errorf("field [%s] %w", name, err)
+		}
+	}
+	return nil
+}
+
+func isNestedStruct(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct && typ != timeType && !reflect.PointerTo(typ).Implements(unmarshalType)
+}
+
+func setField(value reflect.Value, field reflect.StructField, values []string) error {
+	switch value.Kind() {
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.Uint8 || len(values) != 1 || reflect.PointerTo(value.Type()).Implements(unmarshalType) {
+			slice := reflect.MakeSlice(value.Type(), len(values), len(values))
+			for i, v := range values {
+				if err := setValue(slice.Index(i), field, v); err != nil {
+					return err
+				}
+			}
+			value.Set(slice)
+			return nil
+		}
+	case reflect.Array:
+		if len(values) != value.Len() {
+			return fmt.Errorf("expects %d values, got %d", value.Len(), len(values))
+		}
+		for i, v := range values {
+			if err := setValue(value.Index(i), field, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return setValue(value, field, values[0])
+}
+
+func setValue(value reflect.Value, field reflect.StructField, s string) error {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return setValue(value.Elem(), field, s)
+	}
+	if value.CanAddr() && value.Addr().Type().Implements(unmarshalType) && value.Type() != timeType {
+		return value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch value.Type() {
+	case timeType:
+		return setTime(value, field, s)
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		if s == "" {
+			s = "false"
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseInt(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseUint(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			s = "0"
+		}
+		f, err := strconv.ParseFloat(s, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	case reflect.Slice:
+		// []byte
+		value.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+func setTime(value reflect.Value, field reflect.StructField, s string) error {
+	if s == "" {
+		value.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+	layout := field.Tag.Get("time_format")
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	t, err := time.ParseInLocation(layout, s, time.Local)
+	if err != nil {
+		return err
+	}
+	value.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// 上传的文件绑定到 *multipart.FileHeader 或 []*multipart.FileHeader 类型的字段
+func mapFiles(obj any, files map[string][]*multipart.FileHeader, tag string) error {
+	value, err := structValue(obj)
+	if err != nil {
+		return err
+	}
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		headers := files[name]
+		if len(headers) == 0 {
+			continue
+		}
+		switch field.Type {
+		case fileHeaderType:
+			value.Field(i).Set(reflect.ValueOf(headers[0]))
+		case reflect.SliceOf(fileHeaderType):
+			value.Field(i).Set(reflect.ValueOf(headers))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package binding
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type page struct {
+	Page int `form:"page" default:"1"`
+	Size int `form:"size" default:"20"`
+}
+
+type searchReq struct {
+	page
+	Name     string    `form:"name" validate:"required"`
+	Tags     []string  `form:"tag" default:"a,b"`
+	Age      *int      `form:"age"`
+	Birthday time.Time `form:"birthday" time_format:"2006-01-02"`
+	Timeout  time.Duration
+	Ignored  string `form:"-"`
+}
+
+func TestFormBinding(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/?name=gee&size=5", strings.NewReader("age=18&birthday=2024-06-01&Timeout=3s&Ignored=x"))
+	r.Header.Set("Content-Type", MIMEPOSTForm)
+	req := &searchReq{}
+	if err := Form.Bind(r, req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Name != "gee" || req.Page != 1 || req.Size != 5 || *req.Age != 18 || req.Timeout != 3*time.Second || req.Ignored != "" {
+		t.Errorf("unexpected result %+v", req)
+	}
+	if strings.Join(req.Tags, ",") != "a,b" || req.Birthday.Format("2006-01-02") != "2024-06-01" {
+		t.Errorf("unexpected tags %v birthday %v", req.Tags, req.Birthday)
+	}
+
+	// 缺少必填参数 经过Validator校验
+	r = httptest.NewRequest(http.MethodGet, "/?page=2", nil)
+	if err := Query.Bind(r, &searchReq{}); err == nil {
+		t.Error("expected validation error for missing name")
+	}
+	r = httptest.NewRequest(http.MethodGet, "/?name=gee&page=x", nil)
+	if err := Query.Bind(r, &searchReq{}); err == nil {
+		t.Error("expected error for invalid int")
+	}
+}
+
+func TestHeaderBinding(t *testing.T) {
+	var req struct {
+		Token string `header:"x-token"`
+		Limit int    `header:"X-Limit" default:"10"`
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Token", "abc")
+	if err := Header.Bind(r, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Token != "abc" || req.Limit != 10 {
+		t.Errorf("unexpected result %+v", req)
+	}
+}
+
+func TestMultipartBinding(t *testing.T) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField("name", "gee")
+	for _, name := range []string{"a.txt", "b.txt"} {
+		fw, _ := mw.CreateFormFile("files", name)
+		fw.Write([]byte(name))
+	}
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var req struct {
+		Name  string                  `form:"name"`
+		File  *multipart.FileHeader   `form:"files"`
+		Files []*multipart.FileHeader `form:"files"`
+	}
+	if err := Multipart.Bind(r, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Name != "gee" || req.File.Filename != "a.txt" || len(req.Files) != 2 {
+		t.Errorf("unexpected result %+v", req)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	tests := []struct {
+		method      string
+		contentType string
+		want        Binding
+	}{
+		{http.MethodGet, MIMEJSON, Form},
+		{http.MethodPost, MIMEJSON, JSON},
+		{http.MethodPost, MIMEXML2, XML},
+		{http.MethodPost, MIMEPROTOBUF, ProtoBuf},
+		{http.MethodPut, MIMEYAML, YAML},
+		{http.MethodPost, MIMEMultipartPOSTForm, Multipart},
+		{http.MethodPost, MIMEPOSTForm, Form},
+	}
+	for _, tt := range tests {
+		if got := Default(tt.method, tt.contentType); got.Name() != tt.want.Name() {
+			t.Errorf("%s %s: got %s, want %s", tt.method, tt.contentType, got.Name(), tt.want.Name())
+		}
+	}
+}
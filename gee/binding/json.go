@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"strings"
 )
 
 type jsonBinding struct {
@@ -67,46 +68,56 @@ func validateParam(obj any, decoder *json.Decoder) error {
 
 func checkParamSlice(of reflect.Type, obj any, decoder *json.Decoder) error {
 	mapValue := make([]map[string]interface{}, 0)
-	_ = decoder.Decode(&mapValue)
-	for i := 0; i < of.NumField(); i++ {
-		field := of.Field(i)
-		name := field.Name
-		jsonName := field.Tag.Get("json")
-		if jsonName != "" {
-			name = jsonName
-		}
-		required := field.Tag.Get("msgo")
-		for _, v := range mapValue {
-			value := v[name]
-			if value == nil && required == "required" {
-				return errors.New(fmt.Sprintf("filed [%s] is not exist,because [%s] is required", jsonName, jsonName))
-			}
-		}
+	if err := decoder.Decode(&mapValue); err != nil {
+		return err
+	}
+	errs := make(ValidationErrors, 0)
+	for i, v := range mapValue {
+		errs = append(errs, missingFields(of, v, fmt.Sprintf("[%d]", i))...)
+	}
+	if len(errs) > 0 {
+		return errs
 	}
 	b, _ := json.Marshal(mapValue)
-	_ = json.Unmarshal(b, obj)
-	return nil
+	return json.Unmarshal(b, obj)
 }
 
 func checkParam(of reflect.Value, obj any, decoder *json.Decoder) error {
 	// 解析为map，然后根据map中的key 进行比对
 	// 判断类型 结构体 才能解析为map
 	mapValue := make(map[string]interface{})
-	_ = decoder.Decode(&mapValue)
+	if err := decoder.Decode(&mapValue); err != nil {
+		return err
+	}
+	if errs := missingFields(of.Type(), mapValue, ""); len(errs) > 0 {
+		return errs
+	}
+	b, _ := json.Marshal(mapValue)
+	return json.Unmarshal(b, obj)
+}
+
+// 找出 gee:"required" 标记但请求中没有的字段 零值也算存在
+// msgo:"required" 为旧的写法
+func missingFields(of reflect.Type, values map[string]interface{}, prefix string) ValidationErrors {
+	errs := make(ValidationErrors, 0)
 	for i := 0; i < of.NumField(); i++ {
-		field := of.Type().Field(i)
+		field := of.Field(i)
 		name := field.Name
-		jsonName := field.Tag.Get("json")
-		if jsonName != "" {
+		if jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ","); jsonName != "" {
 			name = jsonName
 		}
-		required := field.Tag.Get("msgo")
-		value := mapValue[name]
-		if value == nil && required == "required" {
-			return errors.New(fmt.Sprintf("filed [%s] is not exist,because [%s] is required", jsonName, jsonName))
+		required := field.Tag.Get("gee")
+		if required == "" {
+			required = field.Tag.Get("msgo")
 		}
+		if required != "required" || values[name] != nil {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		errs = append(errs, newFieldError(path, name, "required", ""))
 	}
-	b, _ := json.Marshal(mapValue)
-	_ = json.Unmarshal(b, obj)
-	return nil
+	return errs
 }
@@ -0,0 +1,33 @@
+package binding
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+type protobufBinding struct{}
+
+func (protobufBinding) Name() string {
+	return "protobuf"
+}
+
+func (b protobufBinding) Bind(r *http.Request, obj any) error {
+	if r.Body == nil {
+		return errors.New("invalid request")
+	}
+	msg, ok := obj.(proto.Message)
+	if !ok {
+		return errors.New("obj is not proto.Message")
+	}
+	buf, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(buf, msg); err != nil {
+		return err
+	}
+	return validate(obj)
+}
@@ -1,12 +1,18 @@
 package binding
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
 	"sync"
 
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
 )
 
 type StructValidator interface {
@@ -18,41 +24,179 @@ type StructValidator interface {
 
 var Validator StructValidator = &defaultValidator{}
 
-type SliceValidationError []error
+// 错误信息默认使用的语言
+var defaultLocale = "en"
 
-func (err SliceValidationError) Error() string {
-	n := len(err)
-	switch n {
-	case 0:
-		return ""
-	default:
-		var b strings.Builder
-		if err[0] != nil {
-			fmt.Fprintf(&b, "[%d]: %s", 0, err[0].Error())
+// FieldError 一个字段的校验错误
+type FieldError struct {
+	// 字段路径 使用json标签中的名称 如 items[0].name
+	Field string `json:"field"`
+	// 校验规则 如 required min
+	Rule string `json:"rule"`
+	// 规则参数 如 min=3 中的3
+	Param string `json:"param,omitempty"`
+	// 翻译后的错误信息
+	Message string `json:"message"`
+	// 错误信息中使用的字段名 路径的最后一段
+	name string
+	fe   validator.FieldError
+}
+
+// ValidationErrors 参数校验失败的字段 validate标签和gee:"required"的错误都使用这个类型
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	var b strings.Builder
+	for i, e := range errs {
+		if i > 0 {
+			b.WriteString("; ")
 		}
-		if n > 1 {
-			for i := 1; i < n; i++ {
-				if err[i] != nil {
-					b.WriteString("\n")
-					fmt.Fprintf(&b, "[%d]: %s", i, err[i].Error())
-				}
-			}
+		b.WriteString(e.Field)
+		b.WriteString(": ")
+		b.WriteString(e.Message)
+	}
+	return b.String()
+}
+
+// Translate 返回locale语言的错误信息 不支持的语言使用默认语言
+func (errs ValidationErrors) Translate(locale string) ValidationErrors {
+	d, ok := Validator.(*defaultValidator)
+	if !ok {
+		return errs
+	}
+	trans := d.translator(locale)
+	translated := make(ValidationErrors, len(errs))
+	for i, e := range errs {
+		e.Message = translateMessage(trans, e)
+		translated[i] = e
+	}
+	return translated
+}
+
+func translateMessage(trans ut.Translator, e FieldError) string {
+	if e.fe != nil {
+		// 没有注册翻译的规则 Translate会返回原始错误
+		if msg := e.fe.Translate(trans); msg != e.fe.Error() {
+			return msg
+		}
+	} else if msg, err := trans.T(e.Rule, e.name, e.Param); err == nil {
+		return msg
+	}
+	if e.Param != "" {
+		return fmt.Sprintf("%s failed on the '%s=%s' rule", e.name, e.Rule, e.Param)
+	}
+	return fmt.Sprintf("%s failed on the '%s' rule", e.name, e.Rule)
+}
+
+// SetDefaultLocale 设置错误信息默认使用的语言 支持 en zh
+func SetDefaultLocale(locale string) error {
+	d, ok := Validator.(*defaultValidator)
+	if !ok {
+		return errors.New("binding: Validator is not the default validator")
+	}
+	d.lazyInit()
+	if _, ok := d.trans[locale]; !ok {
+		return fmt.Errorf("binding: unsupported locale %s", locale)
+	}
+	defaultLocale = locale
+	return nil
+}
+
+// MatchLocale 按Accept-Language选择支持的语言 没有匹配时返回默认语言
+// zh-CN,zh;q=0.9,en;q=0.8 -> zh
+func MatchLocale(acceptLanguage string) string {
+	d, ok := Validator.(*defaultValidator)
+	if !ok {
+		return defaultLocale
+	}
+	d.lazyInit()
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if i := strings.IndexAny(tag, "-_"); i > 0 {
+			tag = tag[:i]
+		}
+		if _, ok := d.trans[tag]; ok {
+			return tag
 		}
-		return b.String()
 	}
+	return defaultLocale
+}
+
+// RegisterRule 注册自定义校验规则
+// messages为各语言的错误信息 {0}为字段名 {1}为规则参数
+//
+//	binding.RegisterRule("mobile", isMobile, map[string]string{"zh": "{0}必须是有效的手机号", "en": "{0} must be a valid mobile number"})
+func RegisterRule(tag string, fn validator.Func, messages map[string]string) error {
+	d, ok := Validator.(*defaultValidator)
+	if !ok {
+		return errors.New("binding: Validator is not the default validator")
+	}
+	d.lazyInit()
+	if err := d.validate.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+	for locale, message := range messages {
+		trans, ok := d.trans[locale]
+		if !ok {
+			return fmt.Errorf("binding: unsupported locale %s", locale)
+		}
+		err := d.validate.RegisterTranslation(tag, trans, func(t ut.Translator) error {
+			return t.Add(tag, message, true)
+		}, func(t ut.Translator, fe validator.FieldError) string {
+			msg, _ := t.T(tag, fe.Field(), fe.Param())
+			return msg
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type defaultValidator struct {
 	one      sync.Once
 	validate *validator.Validate
+	trans    map[string]ut.Translator
 }
 
 func (d *defaultValidator) lazyInit() {
 	d.one.Do(func() {
 		d.validate = validator.New()
+		// 错误中的字段名使用json、form、uri、header标签中的名称
+		d.validate.RegisterTagNameFunc(fieldName)
+		enLocale := en.New()
+		uni := ut.New(enLocale, enLocale, zh.New())
+		d.trans = make(map[string]ut.Translator)
+		d.trans["en"], _ = uni.GetTranslator("en")
+		d.trans["zh"], _ = uni.GetTranslator("zh")
+		if err := enTranslations.RegisterDefaultTranslations(d.validate, d.trans["en"]); err != nil {
+			panic(err)
+		}
+		if err := zhTranslations.RegisterDefaultTranslations(d.validate, d.trans["zh"]); err != nil {
+			panic(err)
+		}
 	})
 }
 
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form", "uri", "header"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func (d *defaultValidator) translator(locale string) ut.Translator {
+	d.lazyInit()
+	if trans, ok := d.trans[locale]; ok {
+		return trans
+	}
+	return d.trans[defaultLocale]
+}
+
 func (d *defaultValidator) Engine() any {
 	d.lazyInit()
 	return d.validate
@@ -60,7 +204,34 @@ func (d *defaultValidator) Engine() any {
 
 func (d *defaultValidator) Validate(obj any) error {
 	d.lazyInit()
-	return d.validate.Struct(obj)
+	return d.convert(d.validate.Struct(obj), "")
+}
+
+// 把validator的错误转换成ValidationErrors prefix为切片元素的下标 如 [0]
+func (d *defaultValidator) convert(err error, prefix string) error {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+	trans := d.translator(defaultLocale)
+	errs := make(ValidationErrors, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		// User.items[0].name 去掉结构体名
+		_, path, _ := strings.Cut(fe.Namespace(), ".")
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		e := FieldError{
+			Field: path,
+			Rule:  fe.Tag(),
+			Param: fe.Param(),
+			name:  fe.Field(),
+			fe:    fe,
+		}
+		e.Message = translateMessage(trans, e)
+		errs = append(errs, e)
+	}
+	return errs
 }
 
 func (d *defaultValidator) ValidateStruct(obj any) error {
@@ -70,27 +241,48 @@ func (d *defaultValidator) ValidateStruct(obj any) error {
 	value := reflect.ValueOf(obj)
 	switch value.Kind() {
 	case reflect.Ptr:
+		if value.IsNil() {
+			return nil
+		}
 		return d.ValidateStruct(value.Elem().Interface())
 	case reflect.Struct:
 		return d.Validate(obj)
 	case reflect.Slice, reflect.Array:
-		count := value.Len()
-		validateRet := make(SliceValidationError, 0)
-		for i := 0; i < count; i++ {
-			if err := d.Validate(value.Index(i).Interface()); err != nil {
-				validateRet = append(validateRet, err)
+		d.lazyInit()
+		errs := make(ValidationErrors, 0)
+		for i := 0; i < value.Len(); i++ {
+			elem := reflect.Indirect(value.Index(i))
+			if elem.Kind() != reflect.Struct {
+				continue
+			}
+			err := d.convert(d.validate.Struct(elem.Interface()), fmt.Sprintf("[%d]", i))
+			var elemErrs ValidationErrors
+			if errors.As(err, &elemErrs) {
+				errs = append(errs, elemErrs...)
+			} else if err != nil {
+				return err
 			}
 		}
-		if len(validateRet) == 0 {
+		if len(errs) == 0 {
 			return nil
-		} else {
-			return validateRet
 		}
+		return errs
 	default:
 		return nil
 	}
 }
 
+// 生成gee:"required"等非validator规则的错误
+func newFieldError(path, name, rule, param string) FieldError {
+	e := FieldError{Field: path, Rule: rule, Param: param, name: name}
+	if d, ok := Validator.(*defaultValidator); ok {
+		e.Message = translateMessage(d.translator(defaultLocale), e)
+	} else {
+		e.Message = fmt.Sprintf("%s failed on the '%s' rule", name, rule)
+	}
+	return e
+}
+
 func validate(obj any) error {
 	return Validator.ValidateStruct(obj)
 }
@@ -0,0 +1,70 @@
+package binding
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type orderItem struct {
+	SkuID int    `json:"sku_id" validate:"min=1"`
+	Code  string `json:"code" validate:"omitempty,order_code"`
+}
+
+type orderReq struct {
+	Name  string      `json:"name" validate:"required"`
+	Items []orderItem `json:"items" validate:"dive"`
+}
+
+func TestValidationErrors(t *testing.T) {
+	err := RegisterRule("order_code", func(fl validator.FieldLevel) bool {
+		return strings.HasPrefix(fl.Field().String(), "NO")
+	}, map[string]string{"zh": "{0}必须以NO开头", "en": "{0} must start with NO"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"items":[{"sku_id":1},{"sku_id":0,"code":"x"}]}`))
+	err = JSON.Bind(r, &orderReq{})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	want := []struct{ field, rule, zh string }{
+		{"name", "required", "name为必填字段"},
+		{"items[1].sku_id", "min", "sku_id最小只能为1"},
+		{"items[1].code", "order_code", "code必须以NO开头"},
+	}
+	zh := errs.Translate("zh")
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors: %v", len(errs), errs)
+	}
+	for i, w := range want {
+		if errs[i].Field != w.field || errs[i].Rule != w.rule || zh[i].Message != w.zh {
+			t.Errorf("error %d: got %s %s %q", i, errs[i].Field, errs[i].Rule, zh[i].Message)
+		}
+	}
+	if errs[2].Message != "code must start with NO" {
+		t.Errorf("default locale: got %q", errs[2].Message)
+	}
+
+	// gee:"required" 要求字段出现 与validate共用错误类型
+	var users []struct {
+		Email string `json:"email" gee:"required"`
+	}
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[{"email":""},{}]`))
+	b := JSON
+	b.IsValidate = true
+	err = b.Bind(r, &users)
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "[1].email" || errs[0].Rule != "required" {
+		t.Errorf("gee required: got %v", err)
+	}
+
+	if MatchLocale("zh-CN,zh;q=0.9,en;q=0.8") != "zh" || MatchLocale("fr") != "en" {
+		t.Error("MatchLocale")
+	}
+}
@@ -0,0 +1,134 @@
+package gee
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// 默认按此顺序读取代理转发的客户端IP
+var defaultClientIPHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"}
+
+// SetTrustedProxies 设置可信代理 支持CIDR和单个IP 如 10.0.0.0/8 127.0.0.1
+// 只有直接连接的地址属于可信代理时 ClientIP才会读取转发的请求头 默认不信任任何代理
+func (e *Engine) SetTrustedProxies(proxies []string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("gee: invalid trusted proxy %q", proxy)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			proxy = fmt.Sprintf("%s/%d", proxy, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("gee: invalid trusted proxy %q: %w", proxy, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	e.trustedProxies = nets
+	return nil
+}
+
+func (e *Engine) isTrustedProxy(ip net.IP) bool {
+	for _, n := range e.trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoteIP 直接连接的地址 不读取任何请求头
+func (c *Context) RemoteIP() string {
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(c.R.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(c.R.RemoteAddr)
+	}
+	return ip
+}
+
+// ClientIP 真实的客户端IP
+// 直接连接的地址是可信代理时 按Engine.ClientIPHeaders的顺序从请求头中取
+// 从右向左跳过可信代理 第一个不可信的地址即客户端
+func (c *Context) ClientIP() string {
+	remote := c.RemoteIP()
+	ip := net.ParseIP(remote)
+	if ip == nil || !c.engine.isTrustedProxy(ip) {
+		return remote
+	}
+	headers := c.engine.ClientIPHeaders
+	if headers == nil {
+		headers = defaultClientIPHeaders
+	}
+	for _, name := range headers {
+		values := c.R.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		var chain []string
+		switch strings.ToLower(name) {
+		case "forwarded":
+			chain = parseForwarded(values)
+		default:
+			for _, v := range values {
+				chain = append(chain, strings.Split(v, ",")...)
+			}
+		}
+		if client, ok := c.engine.clientFromChain(chain); ok {
+			return client
+		}
+	}
+	return remote
+}
+
+// 从右向左跳过可信代理 返回第一个不可信的地址
+// 在此之前遇到不合法的地址时返回false 改用下一个请求头 更左边的地址由客户端伪造 不影响结果
+func (e *Engine) clientFromChain(chain []string) (string, bool) {
+	if len(chain) == 0 {
+		return "", false
+	}
+	var ip net.IP
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip = parseForwardedIP(chain[i])
+		if ip == nil {
+			return "", false
+		}
+		if !e.isTrustedProxy(ip) {
+			return ip.String(), true
+		}
+	}
+	// 全部是可信代理 取最左边的
+	return ip.String(), true
+}
+
+// Forwarded: for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"
+func parseForwarded(values []string) []string {
+	var chain []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					chain = append(chain, strings.Trim(value, `"`))
+				}
+			}
+		}
+	}
+	return chain
+}
+
+// 支持带端口和方括号的写法
+func parseForwardedIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
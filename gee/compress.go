@@ -0,0 +1,386 @@
+package gee
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Compressor 压缩响应体的写入器 gzip.Writer zlib.Writer 和常见的zstd实现都满足
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+	// Reset 丢弃状态 改为写入w 用于复用
+	Reset(w io.Writer)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]func(w io.Writer, level int) (Compressor, error){
+		"gzip": func(w io.Writer, level int) (Compressor, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+		// HTTP中的deflate是zlib格式 不是原始的DEFLATE数据
+		"deflate": func(w io.Writer, level int) (Compressor, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+	}
+)
+
+// RegisterCompressor 注册Content-Encoding对应的压缩方式 内置gzip和deflate
+// 需要zstd时在这里注册 如基于klauspost/compress/zstd的实现 再加入CompressOptions.Encodings
+func RegisterCompressor(encoding string, fn func(w io.Writer, level int) (Compressor, error)) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[strings.ToLower(encoding)] = fn
+}
+
+// CompressOptions 响应压缩的配置
+type CompressOptions struct {
+	// 压缩级别 默认为各压缩方式的默认级别 -1
+	Level int
+	// 按优先顺序排列的压缩方式 默认为 gzip deflate
+	Encodings []string
+	// 小于此大小的响应不压缩 默认1024 流式响应在Flush时总是压缩
+	MinLength int
+	// 不压缩的Content-Type前缀 默认为图片 音视频和常见压缩包
+	ExcludedContentTypes []string
+	// 返回true的请求不压缩
+	Skip func(ctx *Context) bool
+}
+
+var defaultExcludedContentTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/pdf",
+}
+
+// Compress 按Accept-Encoding压缩响应体
+// 已设置Content-Encoding(如预压缩的静态文件)、部分内容和不带响应体的响应不会被压缩
+func Compress(opts CompressOptions) MiddlewareFunc {
+	if opts.Level == 0 {
+		opts.Level = gzip.DefaultCompression
+	}
+	if len(opts.Encodings) == 0 {
+		opts.Encodings = []string{"gzip", "deflate"}
+	}
+	if opts.MinLength <= 0 {
+		opts.MinLength = 1024
+	}
+	if opts.ExcludedContentTypes == nil {
+		opts.ExcludedContentTypes = defaultExcludedContentTypes
+	}
+	compressorsMu.RLock()
+	pools := make(map[string]*sync.Pool, len(opts.Encodings))
+	for i, encoding := range opts.Encodings {
+		encoding = strings.ToLower(encoding)
+		opts.Encodings[i] = encoding
+		fn, ok := compressors[encoding]
+		if !ok {
+			compressorsMu.RUnlock()
+			panic("gee: compressor " + encoding + " not registered")
+		}
+		// 提前检查压缩级别
+		if _, err := fn(io.Discard, opts.Level); err != nil {
+			compressorsMu.RUnlock()
+			panic(err)
+		}
+		pools[encoding] = &sync.Pool{New: func() any {
+			c, _ := fn(io.Discard, opts.Level)
+			return c
+		}}
+	}
+	compressorsMu.RUnlock()
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			if opts.Skip != nil && opts.Skip(ctx) {
+				next(ctx)
+				return
+			}
+			ctx.W.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(ctx.R.Header.Get("Accept-Encoding"), opts.Encodings)
+			// 不需要压缩 以及WebSocket升级请求
+			if encoding == "" || ctx.R.Method == http.MethodHead || ctx.R.Header.Get("Upgrade") != "" {
+				next(ctx)
+				return
+			}
+			cw := &compressWriter{
+				ResponseWriter: ctx.writer.ResponseWriter,
+				opts:           &opts,
+				encoding:       encoding,
+				pool:           pools[encoding],
+			}
+			ctx.writer.ResponseWriter = cw
+			defer func() {
+				// 发生panic时丢弃未写出的数据 交给外层的Recovery
+				if err := recover(); err != nil {
+					ctx.writer.ResponseWriter = cw.ResponseWriter
+					cw.release()
+					panic(err)
+				}
+			}()
+			next(ctx)
+			cw.close()
+			ctx.writer.ResponseWriter = cw.ResponseWriter
+		}
+	}
+}
+
+// 按Accept-Encoding的q值选择 q相同时按offered的顺序
+func negotiateEncoding(acceptEncoding string, offered []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	// 头中出现过的压缩方式 包括q=0的
+	named := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, _, _ := strings.Cut(part, ";")
+		named[strings.ToLower(strings.TrimSpace(coding))] = true
+	}
+	for _, spec := range parseAccept(acceptEncoding) {
+		if spec.mime == "*" {
+			// 其他未提到的压缩方式
+			for _, encoding := range offered {
+				if !named[encoding] {
+					return encoding
+				}
+			}
+			continue
+		}
+		for _, encoding := range offered {
+			if spec.mime == encoding {
+				return encoding
+			}
+		}
+	}
+	return ""
+}
+
+// 压缩响应的ResponseWriter 先缓存MinLength字节再决定是否压缩
+type compressWriter struct {
+	http.ResponseWriter
+	opts     *CompressOptions
+	encoding string
+	pool     *sync.Pool
+	// 处理方法设置的状态码 决定是否压缩后再写出
+	status int
+	buf    []byte
+	// 已决定是否压缩
+	decided bool
+	c       Compressor
+	// 连接已被接管
+	hijacked bool
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	// 1xx为中间状态 直接写出
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+	// 不带响应体的状态码不用等待写入
+	if code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusSwitchingProtocols {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.c != nil {
+			return w.c.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) < w.opts.MinLength {
+		return len(b), nil
+	}
+	w.decide(true)
+	if err := w.writeBuffered(); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// decide 决定是否压缩并写出响应头 large表示响应体大小满足MinLength
+func (w *compressWriter) decide(large bool) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// 避免net/http对压缩后的数据做类型嗅探
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if large && w.compressible() {
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		header.Set("Content-Encoding", w.encoding)
+		// 压缩后内容不再逐字节一致
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.c = w.pool.Get().(Compressor)
+		w.c.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *compressWriter) compressible() bool {
+	if w.status < 200 || w.status == http.StatusNoContent || w.status == http.StatusNotModified ||
+		w.status == http.StatusPartialContent {
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := strings.ToLower(header.Get("Content-Type"))
+	for _, excluded := range w.opts.ExcludedContentTypes {
+		if strings.HasPrefix(contentType, excluded) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *compressWriter) writeBuffered() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	if w.c != nil {
+		_, err := w.c.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// Flush 流式响应 如SSE 不再等待MinLength
+func (w *compressWriter) Flush() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided {
+		w.decide(true)
+	}
+	if err := w.writeBuffered(); err != nil {
+		return
+	}
+	if w.c != nil {
+		if err := w.c.Flush(); err != nil {
+			return
+		}
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// 处理方法返回后 写出剩余数据并回收压缩器
+func (w *compressWriter) close() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided {
+		// 没有写入任何内容时交给外层处理
+		if w.status == 0 && len(w.buf) == 0 {
+			return
+		}
+		w.decide(len(w.buf) >= w.opts.MinLength)
+	}
+	_ = w.writeBuffered()
+	w.release()
+}
+
+// 结束压缩流并回收压缩器
+func (w *compressWriter) release() {
+	w.buf = nil
+	if w.c == nil {
+		return
+	}
+	_ = w.c.Close()
+	w.c.Reset(io.Discard)
+	w.pool.Put(w.c)
+	w.c = nil
+}
+
+// 解压后请求体的默认大小限制
+const defaultDecompressMaxSize = 32 << 20
+
+// Decompress 解压Content-Encoding为gzip或deflate的请求体
+// maxSize为解压后的最大字节数 超出时读取请求体返回*http.MaxBytesError
+// 为0时使用默认的32MB 小于0时不限制
+func Decompress(maxSize int64) MiddlewareFunc {
+	if maxSize == 0 {
+		maxSize = defaultDecompressMaxSize
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			encoding := strings.ToLower(strings.TrimSpace(ctx.R.Header.Get("Content-Encoding")))
+			if ctx.R.Body == nil || ctx.R.Body == http.NoBody || (encoding != "gzip" && encoding != "deflate") {
+				next(ctx)
+				return
+			}
+			var body io.ReadCloser
+			var err error
+			if encoding == "gzip" {
+				body, err = gzip.NewReader(ctx.R.Body)
+			} else {
+				body, err = zlib.NewReader(ctx.R.Body)
+			}
+			if err != nil {
+				ctx.Abort()
+				ctx.Error(NewProblem(http.StatusBadRequest, "invalid "+encoding+" body: "+err.Error()))
+				return
+			}
+			if maxSize > 0 {
+				body = http.MaxBytesReader(ctx.W, body, maxSize)
+			}
+			raw := ctx.R.Body
+			ctx.R.Body = &decompressBody{ReadCloser: body, raw: raw}
+			ctx.R.Header.Del("Content-Encoding")
+			ctx.R.Header.Del("Content-Length")
+			ctx.R.ContentLength = -1
+			next(ctx)
+		}
+	}
+}
+
+// 关闭时同时关闭原始请求体
+type decompressBody struct {
+	io.ReadCloser
+	raw io.ReadCloser
+}
+
+func (b *decompressBody) Close() error {
+	return errors.Join(b.ReadCloser.Close(), b.raw.Close())
+}
@@ -0,0 +1,185 @@
+package gee
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("gee ", 1000)
+	engine := New()
+	engine.AddMiddlewareFunc(Compress(CompressOptions{}))
+	g := engine.Group("c")
+	g.Get("/large", func(ctx *Context) {
+		ctx.String(http.StatusOK, large)
+	})
+	g.Get("/small", func(ctx *Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	g.Get("/png", func(ctx *Context) {
+		ctx.W.Header().Set("Content-Type", "image/png")
+		ctx.W.Write([]byte(large))
+	})
+	g.Get("/sse", func(ctx *Context) {
+		ctx.SSEvent("msg", "hello")
+	})
+	g.StaticFS("/static", fstest.MapFS{
+		"app.js":    {Data: []byte(large), ModTime: time.Unix(1700000000, 0)},
+		"app.js.gz": {Data: []byte("precompressed"), ModTime: time.Unix(1700000000, 0)},
+	}, StaticOptions{Precompressed: true})
+
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		encoding       string
+		body           string
+	}{
+		{"/c/large", "gzip, deflate", "gzip", large},
+		{"/c/large", "gzip;q=0.5, deflate", "deflate", large},
+		{"/c/large", "br", "", large},
+		{"/c/large", "", "", large},
+		{"/c/small", "gzip", "", "ok"},
+		{"/c/png", "gzip", "", large},
+		{"/c/sse", "gzip", "gzip", "event:msg\ndata:hello\n\n"},
+		// 预压缩的文件原样返回
+		{"/c/static/app.js", "gzip", "gzip", "precompressed"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s %q: encoding %q", tt.path, tt.acceptEncoding, got)
+			continue
+		}
+		if !strings.Contains(w.Header().Get("Vary"), "Accept-Encoding") {
+			t.Errorf("%s: missing Vary", tt.path)
+		}
+		var body io.Reader = w.Body
+		if strings.HasPrefix(tt.path, "/c/static") {
+			tt.encoding = ""
+		}
+		switch tt.encoding {
+		case "gzip":
+			gr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = gr
+		case "deflate":
+			zr, err := zlib.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = zr
+		}
+		data, _ := io.ReadAll(body)
+		if string(data) != tt.body {
+			t.Errorf("%s %q: body %q", tt.path, tt.acceptEncoding, data)
+		}
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	offered := []string{"gzip", "deflate"}
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"*", "gzip"},
+		// x-gzip不是gzip
+		{"x-gzip, *", "gzip"},
+		{"gzip;q=0, *", "deflate"},
+		{"GZIP, *", "gzip"},
+		{"br, *;q=0.5", "gzip"},
+		{"gzip, deflate, *", "gzip"},
+		{"gzip;q=0, deflate;q=0, *", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.acceptEncoding, offered); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.acceptEncoding, got, tt.want)
+		}
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	engine := New()
+	g := engine.Group("upload")
+	g.Post("", func(ctx *Context) {
+		data, err := io.ReadAll(ctx.R.Body)
+		if err != nil {
+			ctx.String(http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		ctx.String(http.StatusOK, string(data))
+	}, Decompress(10))
+
+	compress := func(s string) *bytes.Buffer {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		gw.Write([]byte(s))
+		gw.Close()
+		return &buf
+	}
+	deflate := func(s string) *bytes.Buffer {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write([]byte(s))
+		zw.Close()
+		return &buf
+	}
+	tests := []struct {
+		encoding string
+		body     io.Reader
+		code     int
+		resp     string
+	}{
+		{"gzip", compress("hello"), http.StatusOK, "hello"},
+		{"gzip", compress("hello world!"), http.StatusRequestEntityTooLarge, ""},
+		{"gzip", strings.NewReader("not gzip"), http.StatusBadRequest, ""},
+		{"deflate", deflate("hello"), http.StatusOK, "hello"},
+		{"deflate", strings.NewReader("not zlib"), http.StatusBadRequest, ""},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/upload", tt.body)
+		r.Header.Set("Content-Encoding", tt.encoding)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code || (tt.resp != "" && w.Body.String() != tt.resp) {
+			t.Errorf("case %d: got %d %s", i, w.Code, w.Body.String())
+		}
+	}
+	// 默认限制解压后的大小 小于0时不限制
+	size := func(ctx *Context) {
+		n, err := io.Copy(io.Discard, ctx.R.Body)
+		if err != nil {
+			ctx.String(http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		ctx.String(http.StatusOK, strconv.FormatInt(n, 10))
+	}
+	g.Post("/default", size, Decompress(0))
+	g.Post("/unlimited", size, Decompress(-1))
+	zeros := string(make([]byte, defaultDecompressMaxSize+1))
+	for path, code := range map[string]int{"/upload/default": http.StatusRequestEntityTooLarge, "/upload/unlimited": http.StatusOK} {
+		r := httptest.NewRequest(http.MethodPost, path, compress(zeros))
+		r.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Errorf("%s: got %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
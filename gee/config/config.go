@@ -2,6 +2,7 @@ package config
 
 import (
 	"flag"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	geeLog "github.com/gee-coder/gee/log"
@@ -18,6 +19,7 @@ type GeeConfig struct {
 	Log      map[string]any
 	Pool     map[string]any
 	Template map[string]any
+	Server   map[string]any
 }
 
 func init() {
@@ -26,7 +28,12 @@ func init() {
 
 func loadToml() {
 	configFile := flag.String("conf", "conf/app.toml", "app config file")
-	flag.Parse()
+	// init中不调用flag.Parse 应用自己的参数和测试的-test.*参数还没有定义 只取出-conf
+	if !flag.Parsed() {
+		if v, ok := confArg(os.Args[1:]); ok {
+			*configFile = v
+		}
+	}
 	if _, err := os.Stat(*configFile); err != nil {
 		Conf.logger.Info("conf/app.toml file not load，because not exist")
 		return
@@ -37,3 +44,25 @@ func loadToml() {
 		return
 	}
 }
+
+// 从命令行参数中找出 -conf path、-conf=path 以及两个横线的写法 与flag包的规则一致
+func confArg(args []string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		// flag包只接受一个或两个横线
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "conf" {
+			continue
+		}
+		if hasValue {
+			return value, true
+		}
+		if i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestConfArg(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+		ok   bool
+	}{
+		{[]string{"-conf", "a.toml"}, "a.toml", true},
+		{[]string{"-v", "--conf=b.toml"}, "b.toml", true},
+		{[]string{"-test.v", "-conf=c.toml", "-test.run=X"}, "c.toml", true},
+		{[]string{"---conf", "d.toml"}, "", false},
+		{[]string{"----conf=e.toml"}, "", false},
+		{[]string{"conf", "f.toml"}, "", false},
+		{[]string{"--", "-conf", "g.toml"}, "", false},
+		{[]string{"-conf"}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := confArg(tt.args)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("confArg(%q) = %q, %v; want %q, %v", tt.args, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package gee
 
 import (
+	"bytes"
 	"errors"
 	"html/template"
 	"io"
@@ -22,9 +23,26 @@ const defaultMultipartMemory = 32 << 20
 
 // 请求上下文
 type Context struct {
-	W                     http.ResponseWriter
-	R                     *http.Request
-	engine                *Engine
+	// 记录状态码和响应大小的ResponseWriter
+	W      ResponseWriter
+	writer responseWriter
+	R      *http.Request
+	engine *Engine
+	params Params
+	// 匹配到的路由 /user/get/:id
+	fullPath string
+	// RequestID 中间件设置的请求ID
+	requestID string
+	// Secure 中间件生成的CSP nonce
+	cspNonce string
+	// CSRF 中间件生成的token和表单字段名
+	csrfToken     string
+	csrfFieldName string
+	// 替换模板占位符 第一次需要时创建
+	replacer *strings.Replacer
+	// Middleware 转换的中间件中 ctx.Next() 要执行的后续处理
+	next                  HandlerFunc
+	aborted               bool
 	queryCache            url.Values
 	formCache             url.Values
 	DisallowUnknownFields bool
@@ -34,6 +52,63 @@ type Context struct {
 	Keys                  map[string]any
 	mu                    sync.RWMutex
 	sameSite              http.SameSite
+	// 通过Error处理过的错误
+	errs []error
+}
+
+// 清空上一个请求留下的数据
+func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
+	c.writer.reset(w)
+	c.W = &c.writer
+	c.R = r
+	c.params = nil
+	c.fullPath = ""
+	c.requestID = ""
+	c.cspNonce = ""
+	c.csrfToken = ""
+	c.csrfFieldName = ""
+	c.replacer = nil
+	c.next = nil
+	c.aborted = false
+	c.queryCache = nil
+	c.formCache = nil
+	c.DisallowUnknownFields = false
+	c.IsValidate = false
+	c.StatusCode = 0
+	c.Logger = c.engine.Logger
+	c.mu.Lock()
+	c.Keys = nil
+	c.mu.Unlock()
+	c.sameSite = 0
+	c.errs = c.errs[:0]
+}
+
+// Copy 复制一份可以在协程中安全使用的上下文 请求处理结束后依然有效
+// 复制的上下文不能写响应 W为nil
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		R:                     c.R,
+		engine:                c.engine,
+		DisallowUnknownFields: c.DisallowUnknownFields,
+		IsValidate:            c.IsValidate,
+		StatusCode:            c.StatusCode,
+		Logger:                c.Logger,
+		sameSite:              c.sameSite,
+		aborted:               c.aborted,
+		fullPath:              c.fullPath,
+		requestID:             c.requestID,
+	}
+	cp.params = make(Params, len(c.params))
+	copy(cp.params, c.params)
+	c.mu.RLock()
+	if c.Keys != nil {
+		cp.Keys = make(map[string]any, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	return cp
 }
 
 func (c *Context) SetSameSite(s http.SameSite) {
@@ -87,31 +162,39 @@ func (c *Context) Get(key string) (value any, exists bool) {
 	return
 }
 
+// HTMLTemplate 解析fileName并执行名为name的模板 解析结果会缓存 文件修改后重新解析
 func (c *Context) HTMLTemplate(name string, funcMap template.FuncMap, data any, fileName ...string) {
-	t := template.New(name)
-	t.Funcs(funcMap)
-	t, err := t.ParseFiles(fileName...)
+	t, err := c.engine.fileTemplates.Files(name, c.engine.templateFuncs(funcMap), fileName...)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	c.W.Header().Set("Content-Type", "text/html; charset=utf-8")
-	err = t.Execute(c.W, data)
+	c.executeTemplate(t, data)
+}
+
+// HTMLTemplateGlob 同HTMLTemplate 解析pattern匹配的全部文件
+func (c *Context) HTMLTemplateGlob(name string, funcMap template.FuncMap, data any, pattern string) {
+	t, err := c.engine.fileTemplates.Glob(name, c.engine.templateFuncs(funcMap), pattern)
 	if err != nil {
 		log.Println(err)
+		return
 	}
+	c.executeTemplate(t, data)
 }
 
-func (c *Context) HTMLTemplateGlob(name string, funcMap template.FuncMap, data any, pattern string) {
-	t := template.New(name)
-	t.Funcs(funcMap)
-	t, err := t.ParseGlob(pattern)
-	if err != nil {
+func (c *Context) executeTemplate(t *template.Template, data any) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
 		log.Println(err)
 		return
 	}
 	c.W.Header().Set("Content-Type", "text/html; charset=utf-8")
-	err = t.Execute(c.W, data)
+	var err error
+	if replacer := c.templateReplacer(); replacer != nil {
+		_, err = replacer.WriteString(c.W, buf.String())
+	} else {
+		_, err = buf.WriteTo(c.W)
+	}
 	if err != nil {
 		log.Println(err)
 	}
@@ -138,17 +221,105 @@ func (c *Context) JSON(code int, data any) error {
 	return c.Render(&render.JSON{Data: data}, code)
 }
 
+// 带缩进的JSON
+func (c *Context) IndentedJSON(code int, data any) error {
+	return c.Render(&render.IndentedJSON{Data: data}, code)
+}
+
+// 返回数组时加上前缀 防止JSON劫持
+func (c *Context) SecureJSON(code int, data any) error {
+	return c.Render(&render.SecureJSON{Prefix: c.engine.SecureJSONPrefix, Data: data}, code)
+}
+
+// 非ASCII字符转义成 \uXXXX
+func (c *Context) AsciiJSON(code int, data any) error {
+	return c.Render(&render.AsciiJSON{Data: data}, code)
+}
+
+// 回调名取自查询参数callback 没有时等同于JSON
+func (c *Context) JSONP(code int, data any) error {
+	callback := c.GetQuery("callback")
+	if callback == "" {
+		return c.JSON(code, data)
+	}
+	if !render.ValidJSONPCallback(callback) {
+		c.Error(NewProblem(http.StatusBadRequest, "invalid JSONP callback"))
+		return render.ErrInvalidCallback
+	}
+	return c.Render(&render.JSONP{Callback: callback, Data: data}, code)
+}
+
+func (c *Context) YAML(code int, data any) error {
+	return c.Render(&render.YAML{Data: data}, code)
+}
+
+// data必须实现proto.Message
+func (c *Context) ProtoBuf(code int, data any) error {
+	return c.Render(&render.ProtoBuf{Data: data}, code)
+}
+
+func (c *Context) MsgPack(code int, data any) error {
+	return c.Render(&render.MsgPack{Data: data}, code)
+}
+
 func (c *Context) HTML(code int, html string) error {
 	return c.Render(&render.HTML{IsTemplate: false, Data: html}, code)
 }
 
 func (c *Context) TemplateHTML(name string, data any) error {
+	return c.templateHTML(http.StatusOK, name, data)
+}
+
+// 执行预加载的模板 所有模板渲染都需要替换cspNonce等模板函数输出的占位符
+func (c *Context) templateHTML(code int, name string, data any) error {
 	return c.Render(&render.HTML{
 		Name:       name,
 		IsTemplate: true,
 		Template:   c.engine.HTMLRender.Template,
 		Data:       data,
-	}, http.StatusOK)
+		Replacer:   c.templateReplacer(),
+	}, code)
+}
+
+// SSEvent 发送一个Server-Sent Events事件并立即刷新到客户端
+func (c *Context) SSEvent(name string, data any) error {
+	err := (&render.SSE{Event: name, Data: data}).Render(c.W, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	c.StatusCode = c.W.Status()
+	c.W.Flush()
+	return nil
+}
+
+// Stream 循环调用step并在每次调用后刷新 step返回false时结束
+// 客户端断开连接时返回true
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	clientGone := c.R.Context().Done()
+	for {
+		select {
+		case <-clientGone:
+			return true
+		default:
+			keepOpen := step(c.W)
+			c.StatusCode = c.W.Status()
+			c.W.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+// HTMLLayout 用布局layout渲染页面name layout为空时只渲染页面 需要先调用Engine.SetTemplateManager
+func (c *Context) HTMLLayout(code int, layout, name string, data any) error {
+	return c.Render(&render.HTMLLayout{
+		Manager:  c.engine.templates,
+		Layout:   layout,
+		Name:     name,
+		Data:     data,
+		Replacer: c.templateReplacer(),
+	}, code)
 }
 
 // 重定向页面
@@ -176,16 +347,62 @@ func (c *Context) FileAttachment(filepath, filename string) {
 }
 
 // 指定文件系统路径 filepath是指定文件系统路径下的路径
+// 使用请求的副本 不修改c.R
 func (c *Context) FileFromFS(filepath string, fs http.FileSystem) {
-	// 恢复路径
-	defer func(old string) {
-		c.R.URL.Path = old
-	}(c.R.URL.Path)
-	c.R.URL.Path = filepath
-	http.FileServer(fs).ServeHTTP(c.W, c.R)
+	r := new(http.Request)
+	*r = *c.R
+	u := *c.R.URL
+	u.Path = filepath
+	u.RawPath = ""
+	r.URL = &u
+	http.FileServer(fs).ServeHTTP(c.W, r)
+}
+
+// Next 执行后续的中间件和处理方法 只在 Middleware 转换的中间件中生效
+func (c *Context) Next() {
+	if c.next == nil {
+		return
+	}
+	next := c.next
+	c.next = nil
+	next(c)
+}
+
+// Abort 中止处理链 内层的中间件和处理方法不再执行 外层中间件可通过 IsAborted 判断
+func (c *Context) Abort() {
+	c.aborted = true
+}
+
+// AbortWithStatus 中止处理链并写入状态码
+func (c *Context) AbortWithStatus(code int) {
+	c.Abort()
+	c.W.WriteHeader(code)
+	c.StatusCode = code
+}
+
+func (c *Context) IsAborted() bool {
+	return c.aborted
+}
+
+// Param 获取路由参数 /user/:id 中的 id 或 /static/*filepath 中的 filepath
+func (c *Context) Param(key string) string {
+	return c.params.ByName(key)
+}
+
+// FullPath 匹配到的完整路由 如 /user/get/:id 未匹配到路由时为空
+func (c *Context) FullPath() string {
+	return c.fullPath
+}
+
+// Params 获取全部路由参数
+func (c *Context) Params() Params {
+	return c.params
 }
 
 func (c *Context) initQueryCache() {
+	if c.queryCache != nil {
+		return
+	}
 	if c.R != nil {
 		c.queryCache = c.R.URL.Query()
 	} else {
@@ -336,31 +553,115 @@ func (c *Context) ShouldBindWith(obj any, b binding.Binding) error {
 func (c *Context) MustBindWith(obj any, b binding.Binding) error {
 	// 如果发生错误，返回400状态码 参数错误
 	if err := c.ShouldBindWith(obj, b); err != nil {
-		c.W.WriteHeader(http.StatusBadRequest)
+		c.bindFail(err)
 		return err
 	}
 	return nil
 }
 
-func (c *Context) BindJson(obj any) error {
+// 绑定失败交给错误处理流程 默认返回400
+func (c *Context) bindFail(err error) {
+	c.Error(c.bindError(err))
+}
+
+// 绑定错误转换成400的问题详情
+// 参数校验失败时带上每个字段的错误 错误信息按Accept-Language翻译
+func (c *Context) bindError(err error) *Problem {
+	problem := NewProblem(http.StatusBadRequest, err.Error())
+	problem.Err = err
+	var errs binding.ValidationErrors
+	if errors.As(err, &errs) {
+		problem.Detail = ""
+		problem.With("errors", errs.Translate(binding.MatchLocale(c.GetHeader("Accept-Language"))))
+	}
+	return problem
+}
+
+// 按上下文的设置生成json绑定
+func (c *Context) jsonBinding() binding.Binding {
 	jsonBinding := binding.JSON
 	jsonBinding.DisallowUnknownFields = c.DisallowUnknownFields
 	jsonBinding.IsValidate = c.IsValidate
-	return c.MustBindWith(obj, jsonBinding)
+	return jsonBinding
+}
+
+func (c *Context) BindJson(obj any) error {
+	return c.MustBindWith(obj, c.jsonBinding())
 }
 
 func (c *Context) BindXML(obj any) error {
 	return c.MustBindWith(obj, binding.XML)
 }
 
+// ContentType 去掉参数的Content-Type application/json; charset=utf-8 -> application/json
+func (c *Context) ContentType() string {
+	contentType, _, _ := strings.Cut(c.GetHeader("Content-Type"), ";")
+	return strings.TrimSpace(contentType)
+}
+
+// ShouldBind 根据请求方法和Content-Type选择绑定方式 出错时不写入状态码
+func (c *Context) ShouldBind(obj any) error {
+	b := binding.Default(c.R.Method, c.ContentType())
+	if b.Name() == binding.JSON.Name() {
+		return c.ShouldBindWith(obj, c.jsonBinding())
+	}
+	return c.ShouldBindWith(obj, b)
+}
+
+// Bind 根据请求方法和Content-Type选择绑定方式 出错时返回400
+// json xml protobuf yaml multipart/form-data 之外的类型按表单处理 GET请求绑定查询参数
+func (c *Context) Bind(obj any) error {
+	b := binding.Default(c.R.Method, c.ContentType())
+	if b.Name() == binding.JSON.Name() {
+		return c.BindJson(obj)
+	}
+	return c.MustBindWith(obj, b)
+}
+
+func (c *Context) BindQuery(obj any) error {
+	return c.MustBindWith(obj, binding.Query)
+}
+
+func (c *Context) BindForm(obj any) error {
+	return c.MustBindWith(obj, binding.Form)
+}
+
+func (c *Context) BindHeader(obj any) error {
+	return c.MustBindWith(obj, binding.Header)
+}
+
+func (c *Context) BindYAML(obj any) error {
+	return c.MustBindWith(obj, binding.YAML)
+}
+
+func (c *Context) BindProtoBuf(obj any) error {
+	return c.MustBindWith(obj, binding.ProtoBuf)
+}
+
+// ShouldBindUri 绑定路由参数 字段使用 uri:"id" 标签
+func (c *Context) ShouldBindUri(obj any) error {
+	params := make(map[string][]string, len(c.params))
+	for _, p := range c.params {
+		params[p.Key] = []string{p.Value}
+	}
+	return binding.Uri.BindUri(params, obj)
+}
+
+func (c *Context) BindUri(obj any) error {
+	if err := c.ShouldBindUri(obj); err != nil {
+		c.bindFail(err)
+		return err
+	}
+	return nil
+}
+
 func (c *Context) Fail(code int, msg string) error {
 	return c.String(code, msg)
 }
 
 func (c *Context) HandleWithError(code int, obj any, err error) {
 	if err != nil {
-		statusCode, data := c.engine.errorHandler(err)
-		c.JSON(statusCode, data)
+		c.Error(err)
 		return
 	}
 	c.JSON(code, obj)
@@ -0,0 +1,192 @@
+package gee
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 这些用例需要配合 go test -race 运行 用来发现请求之间的数据串用和竞争
+
+func TestContextReset(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	g.Get("/set", func(ctx *Context) {
+		ctx.Set("user", "admin")
+		ctx.SetSameSite(http.SameSiteStrictMode)
+		ctx.IsValidate = true
+		ctx.GetQuery("id")
+		ctx.String(http.StatusCreated, "set")
+	})
+	g.Get("/get", func(ctx *Context) {
+		_, exists := ctx.Get("user")
+		state := fmt.Sprintf("%v %d %v %v %s", exists, ctx.StatusCode, ctx.sameSite, ctx.IsValidate, ctx.GetQuery("id"))
+		ctx.String(http.StatusOK, state)
+	})
+
+	// 同一个协程中串行执行 池中大概率拿到同一个上下文
+	for i := 0; i < 10; i++ {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/set?id=1", nil))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/get?id=2", nil))
+		if want := "false 0 0 false 2"; w.Body.String() != want {
+			t.Fatalf("got %q, want %q", w.Body.String(), want)
+		}
+	}
+}
+
+func TestContextCopy(t *testing.T) {
+	engine := New()
+	var wg sync.WaitGroup
+	results := make(chan [2]string, 100)
+	engine.Group("user").Get("/:id", func(ctx *Context) {
+		ctx.Set("id", ctx.Param("id"))
+		cp := ctx.Copy()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, _ := cp.Get("id")
+			results <- [2]string{cp.Param("id"), id.(string)}
+		}()
+	})
+
+	for i := 0; i < 100; i++ {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/"+strconv.Itoa(i), nil))
+	}
+	wg.Wait()
+	close(results)
+	for result := range results {
+		if result[0] != result[1] {
+			t.Errorf("copied context mixed up: param %s, key %s", result[0], result[1])
+		}
+	}
+}
+
+func TestEngineConcurrent(t *testing.T) {
+	engine := New()
+	engine.AddMiddlewareFunc(func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			ctx.Set("query", ctx.GetQuery("n"))
+			next(ctx)
+		}
+	})
+	engine.Group("user").Get("/:id", func(ctx *Context) {
+		query, _ := ctx.Get("query")
+		ctx.String(http.StatusOK, "%s-%v-%d", ctx.Param("id"), query, len(ctx.Keys))
+	})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				id := strconv.Itoa(g*1000 + i)
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/"+id+"?n="+id, nil))
+				if want := id + "-" + id + "-1"; w.Body.String() != want {
+					t.Errorf("got %q, want %q", w.Body.String(), want)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestContextBind(t *testing.T) {
+	type userUri struct {
+		ID int `uri:"id" validate:"min=1"`
+	}
+	type user struct {
+		Name string `json:"name" yaml:"name" form:"name" validate:"required"`
+	}
+	engine := New()
+	engine.Group("user").Post("/:id", func(ctx *Context) {
+		uri := &userUri{}
+		if err := ctx.BindUri(uri); err != nil {
+			return
+		}
+		u := &user{}
+		if err := ctx.Bind(u); err != nil {
+			return
+		}
+		ctx.String(http.StatusOK, "%d %s", uri.ID, u.Name)
+	})
+
+	tests := []struct {
+		contentType string
+		body        string
+		code        int
+	}{
+		{"application/json; charset=utf-8", `{"name":"gee"}`, http.StatusOK},
+		{"application/x-yaml", "name: gee", http.StatusOK},
+		{"application/x-www-form-urlencoded", "name=gee", http.StatusOK},
+		{"application/json", `{}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/user/7", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.contentType)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code || (tt.code == http.StatusOK && w.Body.String() != "7 gee") {
+			t.Errorf("%s: got %d %q", tt.contentType, w.Code, w.Body.String())
+		}
+	}
+
+	// 校验失败返回各字段的错误 按Accept-Language翻译
+	r := httptest.NewRequest(http.MethodPost, "/user/0", nil)
+	r.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	want := `{"title":"Bad Request","status":400,"instance":"/user/0","errors":[{"field":"id","rule":"min","param":"1","message":"id最小只能为1"}]}`
+	if w.Code != http.StatusBadRequest || w.Body.String() != want || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("validation: got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestContextStream(t *testing.T) {
+	engine := New()
+	g := engine.Group("order")
+	g.Get("/events", func(ctx *Context) {
+		ctx.SSEvent("status", "paid\nshipped")
+		ctx.SSEvent("", map[string]int{"id": 1})
+	})
+	stopped := make(chan bool, 1)
+	g.Get("/stream", func(ctx *Context) {
+		i := 0
+		stopped <- ctx.Stream(func(w io.Writer) bool {
+			i++
+			fmt.Fprintf(w, "%d;", i)
+			return i < 3
+		})
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/order/events", nil))
+	want := "event:status\ndata:paid\ndata:shipped\n\ndata:{\"id\":1}\n\n"
+	if w.Body.String() != want || w.Header().Get("Content-Type") != "text/event-stream" || !w.Flushed {
+		t.Errorf("SSEvent: got %q %q flushed=%v", w.Body.String(), w.Header().Get("Content-Type"), w.Flushed)
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/order/stream", nil))
+	if w.Body.String() != "1;2;3;" || <-stopped {
+		t.Errorf("Stream: got %q", w.Body.String())
+	}
+
+	// 客户端断开后不再调用step
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/order/stream", nil).WithContext(ctx))
+	if w.Body.String() != "" || !<-stopped {
+		t.Errorf("Stream after disconnect: got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,142 @@
+package gee
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions 跨域配置
+type CORSOptions struct {
+	// 允许的来源 "*"为全部 支持"https://*.example.com"匹配子域名
+	AllowOrigins []string
+	// 自定义来源判断 与AllowOrigins满足其一即可
+	AllowOriginFunc func(origin string) bool
+	// 预检时返回的请求类型 为空时返回路由实际支持的请求类型
+	AllowMethods []string
+	// 预检时返回的请求头 为空时原样返回Access-Control-Request-Headers
+	AllowHeaders []string
+	// 允许浏览器读取的响应头
+	ExposeHeaders []string
+	// 允许携带Cookie 不能与AllowOrigins中的"*"同时使用
+	AllowCredentials bool
+	// 预检结果的缓存时间
+	MaxAge time.Duration
+}
+
+// CORS 跨域中间件 需要添加为全局或组中间件
+// 路由没有注册OPTIONS时 预检请求同样经过全局和组的中间件
+func CORS(opts CORSOptions) MiddlewareFunc {
+	allowAll := false
+	exact := make(map[string]bool)
+	// 通配子域名 拆分为前缀和后缀 https:// .example.com
+	var wildcards [][2]string
+	for _, origin := range opts.AllowOrigins {
+		origin = strings.ToLower(origin)
+		if origin == "*" {
+			allowAll = true
+		} else if i := strings.Index(origin, "*"); i >= 0 {
+			wildcards = append(wildcards, [2]string{origin[:i], origin[i+1:]})
+		} else {
+			exact[origin] = true
+		}
+	}
+	allowed := func(origin string) bool {
+		if allowAll {
+			return true
+		}
+		lower := strings.ToLower(origin)
+		if exact[lower] {
+			return true
+		}
+		for _, w := range wildcards {
+			if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+				return true
+			}
+		}
+		return opts.AllowOriginFunc != nil && opts.AllowOriginFunc(origin)
+	}
+	// 任何网站都能读取带Cookie的响应 CSRF防护也会失效
+	if allowAll && opts.AllowCredentials {
+		panic("gee: CORS AllowOrigins \"*\" cannot be used with AllowCredentials, list the origins or use AllowOriginFunc")
+	}
+	allowMethods := strings.Join(opts.AllowMethods, ", ")
+	allowHeaders := strings.Join(opts.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(opts.ExposeHeaders, ", ")
+	maxAge := ""
+	if opts.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(opts.MaxAge / time.Second))
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			origin := ctx.R.Header.Get("Origin")
+			header := ctx.W.Header()
+			// 响应内容随来源变化 避免缓存混用
+			if !allowAll {
+				header.Add("Vary", "Origin")
+			}
+			if origin == "" {
+				next(ctx)
+				return
+			}
+			preflight := ctx.R.Method == http.MethodOptions && ctx.R.Header.Get("Access-Control-Request-Method") != ""
+			var routeMethods []string
+			if preflight {
+				// 路径不存在时不应答预检 交给404处理
+				if routeMethods = ctx.engine.allowedMethods(ctx.R.URL.Path); len(routeMethods) == 0 {
+					next(ctx)
+					return
+				}
+			}
+			if !allowed(origin) {
+				if preflight {
+					ctx.Abort()
+					ctx.W.WriteHeader(http.StatusForbidden)
+					ctx.StatusCode = http.StatusForbidden
+					return
+				}
+				// 不返回跨域响应头 由浏览器拦截
+				next(ctx)
+				return
+			}
+			if allowAll {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+			if opts.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if !preflight {
+				if exposeHeaders != "" {
+					header.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+				next(ctx)
+				return
+			}
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			methods := allowMethods
+			if methods == "" {
+				methods = strings.Join(routeMethods, ", ")
+			}
+			if methods != "" {
+				header.Set("Access-Control-Allow-Methods", methods)
+			}
+			headers := allowHeaders
+			if headers == "" {
+				headers = ctx.R.Header.Get("Access-Control-Request-Headers")
+			}
+			if headers != "" {
+				header.Set("Access-Control-Allow-Headers", headers)
+			}
+			if maxAge != "" {
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+			ctx.Abort()
+			ctx.W.WriteHeader(http.StatusNoContent)
+			ctx.StatusCode = http.StatusNoContent
+		}
+	}
+}
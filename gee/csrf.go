@@ -0,0 +1,129 @@
+package gee
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"time"
+)
+
+// token的字节数
+const csrfTokenLength = 32
+
+// CSRFOptions CSRF中间件的配置
+type CSRFOptions struct {
+	// 保存token的Cookie (_csrf)
+	CookieName string
+	// 读取token的请求头 (X-CSRF-Token) 供ajax请求使用
+	HeaderName string
+	// 读取token的表单字段 (csrf_token) 模板中的csrfField使用同样的名字
+	FieldName string
+	// Cookie的Path (/) Domain 有效期(12小时)
+	CookiePath   string
+	CookieDomain string
+	MaxAge       time.Duration
+	// Cookie只通过HTTPS发送 为false时按请求是否为HTTPS决定
+	Secure bool
+	// Cookie的SameSite (Lax)
+	SameSite http.SameSite
+	// 返回true的请求不校验 如使用Bearer token的接口
+	Skip func(ctx *Context) bool
+}
+
+// CSRF 双重提交Cookie方式的CSRF防护
+// Cookie中保存随机token 页面中的token每次请求重新掩码 避免BREACH攻击
+// POST PUT PATCH DELETE等请求需要通过请求头或表单字段带上token 校验失败返回403
+func CSRF(opts CSRFOptions) MiddlewareFunc {
+	if opts.CookieName == "" {
+		opts.CookieName = "_csrf"
+	}
+	if opts.HeaderName == "" {
+		opts.HeaderName = "X-CSRF-Token"
+	}
+	if opts.FieldName == "" {
+		opts.FieldName = "csrf_token"
+	}
+	if opts.CookiePath == "" {
+		opts.CookiePath = "/"
+	}
+	if opts.MaxAge == 0 {
+		opts.MaxAge = 12 * time.Hour
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			ctx.W.Header().Add("Vary", "Cookie")
+			var secret []byte
+			if cookie, err := ctx.R.Cookie(opts.CookieName); err == nil {
+				secret, _ = base64.RawURLEncoding.DecodeString(cookie.Value)
+			}
+			if len(secret) != csrfTokenLength {
+				secret = make([]byte, csrfTokenLength)
+				_, _ = rand.Read(secret)
+				http.SetCookie(ctx.W, &http.Cookie{
+					Name:     opts.CookieName,
+					Value:    base64.RawURLEncoding.EncodeToString(secret),
+					Path:     opts.CookiePath,
+					Domain:   opts.CookieDomain,
+					MaxAge:   int(opts.MaxAge / time.Second),
+					Secure:   opts.Secure || ctx.IsHTTPS(),
+					HttpOnly: true,
+					SameSite: opts.SameSite,
+				})
+			}
+			ctx.csrfToken = maskCSRFToken(secret)
+			ctx.csrfFieldName = opts.FieldName
+			if !safeMethod(ctx.R.Method) && (opts.Skip == nil || !opts.Skip(ctx)) {
+				token := ctx.R.Header.Get(opts.HeaderName)
+				if token == "" {
+					token = ctx.R.PostFormValue(opts.FieldName)
+				}
+				if !validCSRFToken(token, secret) {
+					ctx.Abort()
+					ctx.Error(NewProblem(http.StatusForbidden, "CSRF token missing or invalid"))
+					return
+				}
+			}
+			next(ctx)
+		}
+	}
+}
+
+// CSRFToken CSRF中间件为本次请求生成的token 放在请求头或表单字段中提交
+func (c *Context) CSRFToken() string {
+	return c.csrfToken
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// 随机pad + pad异或secret
+func maskCSRFToken(secret []byte) string {
+	token := make([]byte, 2*csrfTokenLength)
+	pad := token[:csrfTokenLength]
+	_, _ = rand.Read(pad)
+	for i, b := range secret {
+		token[csrfTokenLength+i] = pad[i] ^ b
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+func validCSRFToken(token string, secret []byte) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != 2*csrfTokenLength {
+		return false
+	}
+	unmasked := make([]byte, csrfTokenLength)
+	for i := range unmasked {
+		unmasked[i] = raw[i] ^ raw[csrfTokenLength+i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
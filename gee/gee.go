@@ -1,19 +1,26 @@
 package gee
 
 import (
+	"context"
 	"fmt"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	geeConfig "github.com/gee-coder/gee/config"
 	"github.com/gee-coder/gee/gateway"
+	"github.com/gee-coder/gee/internal/requestid"
 	geeLog "github.com/gee-coder/gee/log"
 	"github.com/gee-coder/gee/register"
 	"github.com/gee-coder/gee/render"
+	"github.com/gee-coder/gee/websocket"
 	"github.com/nacos-group/nacos-sdk-go/v2/clients/naming_client"
 	"github.com/nacos-group/nacos-sdk-go/v2/vo"
 )
@@ -24,22 +31,56 @@ const ANY = "ANY"
 type HandlerFunc func(ctx *Context)
 
 // 中间件
+// 执行顺序由外到内：全局中间件、上级组中间件、组中间件、路由中间件 同一级按添加顺序
+// 先添加的中间件在最外层 最先执行前置代码、最后执行后置代码
 type MiddlewareFunc func(handlerFunc HandlerFunc) HandlerFunc
 
+// Middleware 把 func(ctx) { 前置代码; ctx.Next(); 后置代码 } 形式的方法转换为中间件
+// 方法内没有调用 ctx.Next() 也没有 ctx.Abort() 时 返回后自动执行后续的处理
+func Middleware(h HandlerFunc) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			prev := ctx.next
+			ctx.next = next
+			h(ctx)
+			if ctx.next != nil {
+				ctx.next = nil
+				next(ctx)
+			}
+			ctx.next = prev
+		}
+	}
+}
+
+// 中止后不再执行内层的中间件和处理方法
+func abortable(h HandlerFunc) HandlerFunc {
+	return func(ctx *Context) {
+		if ctx.IsAborted() {
+			return
+		}
+		h(ctx)
+	}
+}
+
 // 路由组
 type routerGroup struct {
-	// 组
+	// 组 嵌套的组为拼接后的完整组名 user/v1
 	groupName string
+	// 上级组 顶层组为nil
+	parent *routerGroup
 	// key1:组下的路由 key2:请求类型 value:处理方法 用来保存处理方法
 	handlerMap map[string]map[string]HandlerFunc
 	// key:组下的路由 value:请求类型数组 用来判断[ip:端口：/组+组下的路由]是否支持此种请求类型
 	handlerMethodMap map[string][]string
-	// 前缀树 每个组下都有一个前缀树 用来匹配具体请求路径
-	treeNode *treeNode
+	engine           *Engine
 	// 组中间件
 	middlewares []MiddlewareFunc
 	// 路由中间件
 	middlewaresFuncMap map[string]map[string][]MiddlewareFunc
+	// key1:组下的路由 key2:请求类型 value:包裹好中间件的处理方法 启动时组装一次
+	handlerChainMap map[string]map[string]HandlerFunc
+	// 自动应答OPTIONS的处理方法 经过全局和组的中间件 CORS预检在这里处理
+	optionsChain HandlerFunc
 }
 
 func (r *routerGroup) handle(routerName string, method string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
@@ -48,40 +89,95 @@ func (r *routerGroup) handle(routerName string, method string, handlerFunc Handl
 		r.handlerMap[routerName] = make(map[string]HandlerFunc)
 		r.middlewaresFuncMap[routerName] = make(map[string][]MiddlewareFunc)
 	}
-	if r.handlerMap[routerName][method] == nil {
-		r.handlerMap[routerName][method] = handlerFunc
-	} else {
+	if r.handlerMap[routerName][method] != nil {
 		panic("[路由：" + routerName + "，方法：" + method + "]已经被注册")
 	}
+	// 组装前缀树 有歧义的路由在启动时直接报错
+	if err := r.engine.addRoute(method, r.fullPath(routerName), &route{group: r, routerName: routerName}); err != nil {
+		panic(err)
+	}
+	r.handlerMap[routerName][method] = handlerFunc
 	r.handlerMethodMap[method] = append(r.handlerMethodMap[method], routerName)
-	// 组装前缀树
-	r.treeNode.Put(routerName)
 	// 组装中间件
 
 	// 组装路由中间件
 	r.middlewaresFuncMap[routerName][method] = append(r.middlewaresFuncMap[routerName][method], middlewareFunc...)
+	// 启动后注册的路由直接组装
+	if r.engine.chainsBuilt {
+		r.buildChain(routerName, method)
+		if r.optionsChain == nil {
+			r.buildOptionsChain()
+		}
+	}
+}
+
+// 组名+组下的路由 user + /get/:id -> /user/get/:id
+func (r *routerGroup) fullPath(routerName string) string {
+	path := r.groupName
+	if path != "" && !strings.HasPrefix(path, SEPARATOR) {
+		path = SEPARATOR + path
+	}
+	path = strings.TrimSuffix(path, SEPARATOR) + routerName
+	if path == "" {
+		return SEPARATOR
+	}
+	return path
 }
 
+// AddMiddlewareFunc 添加组中间件 需在处理第一个请求前添加
 func (r *routerGroup) AddMiddlewareFunc(middlewares ...MiddlewareFunc) {
+	r.engine.checkChainsNotBuilt()
 	r.middlewares = append(r.middlewares, middlewares...)
 }
 
-func (r *routerGroup) methodHandle(routerName string, method string, h HandlerFunc, ctx *Context) {
-	// 通用的组中间件
-	if r.middlewares != nil {
-		// 包裹n层中间件
-		for _, middlewareFunc := range r.middlewares {
-			h = middlewareFunc(h)
-		}
+// Group 创建子组 组名与中间件都继承自当前组
+func (r *routerGroup) Group(name string) *routerGroup {
+	g := newRouterGroup(r.engine, strings.Trim(r.groupName, SEPARATOR)+SEPARATOR+strings.Trim(name, SEPARATOR))
+	g.parent = r
+	r.engine.routerGroups = append(r.engine.routerGroups, g)
+	return g
+}
+
+// 全局中间件、各级上级组的中间件、本组的中间件 按此顺序排列
+// 全局中间件在请求时读取 组创建后添加的全局中间件同样生效
+func (r *routerGroup) groupMiddlewares() []MiddlewareFunc {
+	middlewares := make([]MiddlewareFunc, 0)
+	for g := r; g != nil; g = g.parent {
+		middlewares = append(g.middlewares[:len(g.middlewares):len(g.middlewares)], middlewares...)
 	}
-	// 路由级别的组中间件
-	if r.middlewaresFuncMap[routerName][method] != nil {
-		// 包裹n层中间件
-		for _, middlewareFunc := range r.middlewaresFuncMap[routerName][method] {
-			h = middlewareFunc(h)
-		}
+	return append(r.engine.middlewares[:len(r.engine.middlewares):len(r.engine.middlewares)], middlewares...)
+}
+
+// 组装处理链 由内向外包裹 保证先添加的中间件在最外层
+func (r *routerGroup) buildChain(routerName string, method string) {
+	middlewares := append(r.groupMiddlewares(), r.middlewaresFuncMap[routerName][method]...)
+	h := r.handlerMap[routerName][method]
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](abortable(h))
+	}
+	if r.handlerChainMap[routerName] == nil {
+		r.handlerChainMap[routerName] = make(map[string]HandlerFunc)
+	}
+	r.handlerChainMap[routerName][method] = h
+}
+
+// 路由没有注册OPTIONS时的应答 Allow已经在响应头中
+func autoOptions(ctx *Context) {
+	ctx.W.WriteHeader(http.StatusNoContent)
+	ctx.StatusCode = http.StatusNoContent
+}
+
+func (r *routerGroup) buildOptionsChain() {
+	middlewares := r.groupMiddlewares()
+	h := HandlerFunc(autoOptions)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](abortable(h))
 	}
-	h(ctx)
+	r.optionsChain = h
+}
+
+func (r *routerGroup) methodHandle(routerName string, method string, ctx *Context) {
+	r.handlerChainMap[routerName][method](ctx)
 }
 
 func (r *routerGroup) Any(routerName string, handlerFunc HandlerFunc, middlewareFunc ...MiddlewareFunc) {
@@ -122,16 +218,20 @@ type router struct {
 	engine       *Engine
 }
 
-func (r *router) Group(name string) *routerGroup {
-	g := &routerGroup{
+func newRouterGroup(engine *Engine, name string) *routerGroup {
+	return &routerGroup{
 		groupName:          name,
 		handlerMap:         make(map[string]map[string]HandlerFunc),
 		handlerMethodMap:   make(map[string][]string),
-		treeNode:           &treeNode{nodeName: SEPARATOR, children: make([]*treeNode, 0)},
+		engine:             engine,
 		middlewares:        make([]MiddlewareFunc, 0),
 		middlewaresFuncMap: make(map[string]map[string][]MiddlewareFunc),
+		handlerChainMap:    make(map[string]map[string]HandlerFunc),
 	}
-	g.AddMiddlewareFunc(r.engine.middlewares...)
+}
+
+func (r *router) Group(name string) *routerGroup {
+	g := newRouterGroup(r.engine, name)
 	r.routerGroups = append(r.routerGroups, g)
 	return g
 }
@@ -141,22 +241,52 @@ type ErrorHandler func(err error) (int, any)
 // 引擎
 type Engine struct {
 	router
+	// key:请求类型 value:该请求类型的路由树 ANY路由会加入每一棵树
+	trees      map[string]*treeNode
 	funcMap    template.FuncMap
 	HTMLRender render.HTMLRender
+	// HTMLLayout 使用的模板管理
+	templates *render.TemplateManager
+	// HTMLTemplate 和 HTMLTemplateGlob 解析的模板
+	fileTemplates render.FileCache
+	// SecureJSON 在数组前加的前缀 默认 while(1);
+	SecureJSONPrefix string
 	// sync.Pool用于存储分配了还没被使用但未来可能被使用的值
 	// sync.Pool大小可伸缩，会动态扩容，池中不活跃的对象会被自动清理
 	pool   sync.Pool
 	Logger *geeLog.Logger
-	// 全局中间件
-	middlewares      []MiddlewareFunc
-	errorHandler     ErrorHandler
-	OpenGateWay      bool
-	gatewayTreeNode  *gateway.TreeNode
-	gatewayConfigs   []gateway.GWConfig
-	gatewayConfigMap map[string]gateway.GWConfig
-	RegisterType     string
-	RegisterOption   register.Option
-	registerClient   register.GeeRegister
+	// 全局中间件 需在处理第一个请求前添加
+	middlewares []MiddlewareFunc
+	chainsOnce  sync.Once
+	chainsBuilt bool
+	// 路由不存在、请求类型不支持时的处理方法 同样经过全局中间件
+	notFoundHandler         HandlerFunc
+	methodNotAllowedHandler HandlerFunc
+	notFoundChain           HandlerFunc
+	methodNotAllowedChain   HandlerFunc
+	errorHandler            ErrorHandler
+	OpenGateWay             bool
+	gatewayTreeNode         *gateway.TreeNode
+	gatewayConfigs          []gateway.GWConfig
+	gatewayConfigMap        map[string]gateway.GWConfig
+	RegisterType            string
+	RegisterOption          register.Option
+	registerClient          register.GeeRegister
+	// 优雅关闭时等待请求处理完成的最长时间 默认10秒
+	ShutdownTimeout time.Duration
+	// 超时时间、请求头大小、监听器、h2c等服务配置
+	ServerOptions ServerOptions
+	// 当前运行的服务 未运行时为nil
+	running    *serverRun
+	serverMu   sync.Mutex
+	onStart    []func() error
+	onShutdown []func(ctx context.Context) error
+	// WebSocket 路由握手使用的配置
+	WebSocketUpgrader websocket.Upgrader
+	// 可信代理 通过SetTrustedProxies设置
+	trustedProxies []*net.IPNet
+	// ClientIP 读取的请求头 默认为 Forwarded X-Forwarded-For X-Real-IP
+	ClientIPHeaders []string
 }
 
 func (e *Engine) SetGatewayConfig(gatewayConfigs []gateway.GWConfig) {
@@ -167,7 +297,12 @@ func (e *Engine) SetGatewayConfig(gatewayConfigs []gateway.GWConfig) {
 	}
 }
 
+// RegisterErrorHandler 自定义错误转换成的响应 返回*Problem时按problem+json输出 其他数据按JSON输出
+// 传nil恢复为DefaultErrorHandler
 func (e *Engine) RegisterErrorHandler(err ErrorHandler) {
+	if err == nil {
+		err = DefaultErrorHandler
+	}
 	e.errorHandler = err
 }
 
@@ -179,9 +314,19 @@ func (e *Engine) SetHtmlTemplate(t *template.Template) {
 	e.HTMLRender = render.HTMLRender{Template: t}
 }
 
+// SetTemplateManager 设置 ctx.HTMLLayout 使用的模板管理 funcMap为空时使用SetFuncMap设置的
+// 同时加入cspNonce csrfToken csrfField等内置模板函数
+func (e *Engine) SetTemplateManager(m *render.TemplateManager) {
+	if m.FuncMap == nil {
+		m.FuncMap = e.funcMap
+	}
+	m.FuncMap = e.templateFuncs(m.FuncMap)
+	e.templates = m
+}
+
 // LoadTemplateGlob 加载所有模板
 func (e *Engine) LoadTemplate(pattern string) {
-	t := template.Must(template.New("").Funcs(e.funcMap).ParseGlob(pattern))
+	t := template.Must(template.New("").Funcs(e.templateFuncs(e.funcMap)).ParseGlob(pattern))
 	e.SetHtmlTemplate(t)
 }
 
@@ -217,6 +362,10 @@ func (e *Engine) httpRequestHandle(ctx *Context) {
 			if _, ok := req.Header["User-Agent"]; !ok {
 				req.Header.Set("User-Agent", "")
 			}
+			// 网关生成请求ID 下游服务的RequestID中间件沿用
+			if !validRequestID(req.Header.Get(requestid.Header)) {
+				req.Header.Set(requestid.Header, newRequestID())
+			}
 			if gwConfig.Header != nil {
 				gwConfig.Header(req)
 			}
@@ -233,44 +382,208 @@ func (e *Engine) httpRequestHandle(ctx *Context) {
 		return
 	}
 
-	for _, group := range e.routerGroups {
-		routerName := SubStringLast(ctx.R.URL.Path, "/"+group.groupName)
-		// get/1
-		node := group.treeNode.Get(routerName)
-		if node != nil && node.isEnd {
-			// 路由匹配上了
-			handle, ok := group.handlerMap[node.routerName][ANY]
-			if ok {
-				group.methodHandle(node.routerName, ANY, handle, ctx)
-				return
+	method := ctx.R.Method
+	path := ctx.R.URL.Path
+	// /user/get/1
+	if node, params := e.getRoute(method, path); node != nil {
+		// 路由匹配上了
+		e.handleRoute(ctx, node, params, method)
+		return
+	}
+	// 没有注册HEAD时执行GET的处理方法 丢弃响应体
+	if method == http.MethodHead {
+		if node, params := e.getRoute(http.MethodGet, path); node != nil {
+			ctx.writer.ResponseWriter = &headResponseWriter{ResponseWriter: ctx.writer.ResponseWriter}
+			e.handleRoute(ctx, node, params, http.MethodGet)
+			return
+		}
+	}
+	// 其他请求类型能匹配上
+	if allowed := e.allowedMethods(path); len(allowed) > 0 {
+		ctx.W.Header().Set("Allow", strings.Join(allowed, ", "))
+		if method == http.MethodOptions {
+			e.handleOptions(ctx, path)
+			return
+		}
+		e.methodNotAllowedChain(ctx)
+		return
+	}
+	e.notFoundChain(ctx)
+}
+
+func (e *Engine) getRoute(method, path string) (*treeNode, Params) {
+	tree, ok := e.trees[method]
+	if !ok {
+		tree = e.trees[ANY]
+	}
+	if tree == nil {
+		return nil, nil
+	}
+	return tree.Get(path)
+}
+
+func (e *Engine) handleRoute(ctx *Context, node *treeNode, params Params, method string) {
+	ctx.params = params
+	ctx.fullPath = node.routerName
+	group, routerName := node.route.group, node.route.routerName
+	if _, ok := group.handlerMap[routerName][ANY]; ok {
+		group.methodHandle(routerName, ANY, ctx)
+		return
+	}
+	group.methodHandle(routerName, method, ctx)
+}
+
+// 自动应答OPTIONS 使用匹配到的路由所在组的中间件
+func (e *Engine) handleOptions(ctx *Context, path string) {
+	var node *treeNode
+	var params Params
+	for _, method := range anyMethods {
+		if tree := e.trees[method]; tree != nil {
+			if node, params = tree.Get(path); node != nil {
+				break
 			}
-			handle, ok = group.handlerMap[node.routerName][ctx.R.Method]
-			if ok {
-				group.methodHandle(node.routerName, ctx.R.Method, handle, ctx)
-				return
+		}
+	}
+	if node == nil {
+		for _, tree := range e.trees {
+			if node, params = tree.Get(path); node != nil {
+				break
 			}
-			ctx.W.WriteHeader(http.StatusMethodNotAllowed)
-			_, err := fmt.Fprintf(ctx.W, "%s %s not allowed \n", ctx.R.RequestURI, ctx.R.Method)
-			if err != nil {
-				log.Println(err)
+		}
+	}
+	ctx.params = params
+	ctx.fullPath = node.routerName
+	node.route.group.optionsChain(ctx)
+}
+
+// 路径支持的全部请求类型 由各组的handlerMap得出
+// 注册了GET的路径同时支持HEAD 匹配到的路径都支持OPTIONS
+func (e *Engine) allowedMethods(path string) []string {
+	allowed := make(map[string]bool)
+	for _, tree := range e.trees {
+		node, _ := tree.Get(path)
+		if node == nil {
+			continue
+		}
+		for method := range node.route.group.handlerMap[node.route.routerName] {
+			if method != ANY {
+				allowed[method] = true
+				continue
+			}
+			for _, m := range anyMethods {
+				if m != ANY {
+					allowed[m] = true
+				}
 			}
-			return
 		}
 	}
-	ctx.W.WriteHeader(http.StatusNotFound)
-	_, err := fmt.Fprintf(ctx.W, "%s  not found \n", ctx.R.RequestURI)
-	if err != nil {
-		log.Println(err)
+	if len(allowed) == 0 {
+		return nil
+	}
+	if allowed[http.MethodGet] {
+		allowed[http.MethodHead] = true
+	}
+	allowed[http.MethodOptions] = true
+	methods := make([]string, 0, len(allowed))
+	for method := range allowed {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// 路由匹配但资源不存在时使用 不再经过全局中间件
+func (e *Engine) handleNotFound(ctx *Context) {
+	if e.notFoundHandler != nil {
+		e.notFoundHandler(ctx)
+		return
+	}
+	notFound(ctx)
+}
+
+func notFound(ctx *Context) {
+	ctx.Error(NewProblem(http.StatusNotFound, ctx.R.RequestURI+" not found"))
+}
+
+func methodNotAllowed(ctx *Context) {
+	ctx.Error(NewProblem(http.StatusMethodNotAllowed, ctx.R.Method+" "+ctx.R.RequestURI+" not allowed"))
+}
+
+// SetNotFoundHandler 自定义路由不存在时的处理方法 需在处理第一个请求前设置
+func (e *Engine) SetNotFoundHandler(h HandlerFunc) {
+	e.notFoundHandler = h
+}
+
+// SetMethodNotAllowedHandler 自定义请求类型不支持时的处理方法 需在处理第一个请求前设置
+// 调用时响应头中已经设置好Allow
+func (e *Engine) SetMethodNotAllowedHandler(h HandlerFunc) {
+	e.methodNotAllowedHandler = h
+}
+
+// 全局中间件包裹的处理方法
+func (e *Engine) buildEngineChain(h HandlerFunc) HandlerFunc {
+	for i := len(e.middlewares) - 1; i >= 0; i-- {
+		h = e.middlewares[i](abortable(h))
 	}
+	return h
+}
+
+// ANY路由加入所有常用请求类型的树 其他请求类型使用ANY树
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+	http.MethodHead, http.MethodOptions, http.MethodConnect, http.MethodTrace, ANY,
+}
+
+func (e *Engine) addRoute(method, path string, r *route) error {
+	methods := []string{method}
+	if method == ANY {
+		methods = anyMethods
+	}
+	for _, m := range methods {
+		tree, ok := e.trees[m]
+		if !ok {
+			tree = &treeNode{}
+			e.trees[m] = tree
+		}
+		if err := tree.Put(path, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 组装所有路由的处理链 中间件只在启动时包裹一次 不在每个请求中重复包裹
+func (e *Engine) buildChains() {
+	e.chainsOnce.Do(func() {
+		for _, group := range e.routerGroups {
+			group.buildOptionsChain()
+			for routerName, methods := range group.handlerMap {
+				for method := range methods {
+					group.buildChain(routerName, method)
+				}
+			}
+		}
+		notFoundHandler, methodNotAllowedHandler := e.notFoundHandler, e.methodNotAllowedHandler
+		if notFoundHandler == nil {
+			notFoundHandler = notFound
+		}
+		if methodNotAllowedHandler == nil {
+			methodNotAllowedHandler = methodNotAllowed
+		}
+		e.notFoundChain = e.buildEngineChain(notFoundHandler)
+		e.methodNotAllowedChain = e.buildEngineChain(methodNotAllowedHandler)
+		e.chainsBuilt = true
+	})
 }
 
 func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.buildChains()
 	ctx := e.pool.Get().(*Context)
-	ctx.W = w
-	ctx.R = r
-	ctx.Logger = e.Logger
+	// 池中取出的上下文可能保留着上一个请求的数据 使用前必须重置
+	ctx.reset(w, r)
 	e.httpRequestHandle(ctx)
 	// 存起来可以不用再次分配内存，提高效率
+	// 放回后ctx会被其他请求复用 处理方法中启动的协程需要使用ctx.Copy()
 	e.pool.Put(ctx)
 }
 
@@ -278,65 +591,42 @@ func (e *Engine) Handler() http.Handler {
 	return e
 }
 
-func (e *Engine) RunTLS(addr, certFile, keyFile string) {
-	err := http.ListenAndServeTLS(addr, certFile, keyFile, e.Handler())
-	if err != nil {
-		log.Fatal(err)
-	}
-}
-
 func (c *Context) SetBasicAuth(username, password string) {
 	c.R.Header.Set("Authorization", "Basic "+BasicAuth(username, password))
 }
 
-func (e *Engine) Run(ports ...string) {
-	if e.RegisterType == "nacos" {
-		r := &register.GeeNacosRegister{}
-		err := r.CreateCli(e.RegisterOption)
-		if err != nil {
-			panic(err)
-		}
-		e.registerClient = r
-	} else if e.RegisterType == "etcd" {
-		r := &register.GeeEtcdRegister{}
-		err := r.CreateCli(e.RegisterOption)
-		if err != nil {
-			panic(err)
-		}
-		e.registerClient = r
-	}
-	port := ":8111"
-	if ports != nil {
-		port = ports[0]
-	}
-	http.Handle(SEPARATOR, e)
-	err := http.ListenAndServe(port, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-}
-
 func (e *Engine) allocateContext() any {
 	return &Context{engine: e}
 }
 
+// AddMiddlewareFunc 添加全局中间件 对之前和之后注册的路由都生效 需在处理第一个请求前添加
 func (e *Engine) AddMiddlewareFunc(middlewares ...MiddlewareFunc) {
+	e.checkChainsNotBuilt()
 	e.middlewares = append(e.middlewares, middlewares...)
 }
 
+// 处理链在启动或第一个请求时组装 之后添加的中间件不会生效
+func (e *Engine) checkChainsNotBuilt() {
+	if e.chainsBuilt {
+		panic("gee: middleware must be added before the engine starts serving requests")
+	}
+}
+
 func New() *Engine {
 	engine := &Engine{
 		router:           router{},
+		trees:            make(map[string]*treeNode),
 		funcMap:          nil,
 		HTMLRender:       render.HTMLRender{},
 		Logger:           geeLog.Default(),
 		gatewayTreeNode:  &gateway.TreeNode{Name: SEPARATOR, Children: make([]*gateway.TreeNode, 0)},
 		gatewayConfigMap: make(map[string]gateway.GWConfig),
+		errorHandler:     DefaultErrorHandler,
 	}
 	engine.pool.New = func() any {
 		return engine.allocateContext()
 	}
-	// r.engine = engine
+	engine.router.engine = engine
 	return engine
 }
 
@@ -347,6 +637,9 @@ func Default() *Engine {
 	if ok {
 		engine.Logger.SetLogPath(logPath.(string))
 	}
+	if err := engine.loadServerConf(geeConfig.Conf.Server); err != nil {
+		panic(err)
+	}
 	engine.AddMiddlewareFunc(Recovery, Logging)
 	engine.router.engine = engine
 	return engine
@@ -357,6 +650,6 @@ func (e *Engine) LoadTemplateGlobByConf() {
 	if !ok {
 		panic("config pattern not exist")
 	}
-	t := template.Must(template.New("").Funcs(e.funcMap).ParseGlob(pattern.(string)))
+	t := template.Must(template.New("").Funcs(e.templateFuncs(e.funcMap)).ParseGlob(pattern.(string)))
 	e.SetHtmlTemplate(t)
 }
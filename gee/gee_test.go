@@ -0,0 +1,553 @@
+package gee
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gee-coder/gee/ratelimit"
+	"github.com/gee-coder/gee/rpc"
+	"github.com/gee-coder/gee/websocket"
+)
+
+func TestContextParam(t *testing.T) {
+	engine := New()
+	group := engine.Group("user")
+	group.Get("/get/:id", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.Param("id"))
+	})
+	group.Get("/file/*filepath", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.Param("filepath"))
+	})
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/user/get/10", "10"},
+		{"/user/file/a/b.txt", "/a/b.txt"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != tt.body {
+			t.Errorf("%s: got %d %q, want 200 %q", tt.path, w.Code, w.Body.String(), tt.body)
+		}
+	}
+}
+
+func TestGroupPrefixAnchored(t *testing.T) {
+	engine := New()
+	engine.Group("orders").Get("/find", func(ctx *Context) {
+		ctx.String(http.StatusOK, "find")
+	})
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/orders/find", http.StatusOK},
+		{"/api/orders/find", http.StatusNotFound},
+		{"/ordersx/find", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: got %d, want %d", tt.path, w.Code, tt.code)
+		}
+	}
+}
+
+func TestNestedGroup(t *testing.T) {
+	engine := New()
+	trace := func(name string) MiddlewareFunc {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx *Context) {
+				ctx.W.Header().Add("X-Trace", name)
+				next(ctx)
+			}
+		}
+	}
+	api := engine.Group("api")
+	api.AddMiddlewareFunc(trace("api"))
+	v1 := api.Group("v1")
+	v1.AddMiddlewareFunc(trace("v1"))
+	v1.Get("/user/:id", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.Param("id"))
+	})
+	// 组创建之后添加的全局中间件也要生效
+	engine.AddMiddlewareFunc(trace("engine"))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/user/7", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "7" {
+		t.Fatalf("got %d %q, want 200 %q", w.Code, w.Body.String(), "7")
+	}
+	if got := len(w.Header().Values("X-Trace")); got != 3 {
+		t.Errorf("got %d middlewares, want 3: %v", got, w.Header().Values("X-Trace"))
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	engine := New()
+	order := make([]string, 0)
+	trace := func(name string) MiddlewareFunc {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx *Context) {
+				order = append(order, name+" before")
+				next(ctx)
+				order = append(order, name+" after")
+			}
+		}
+	}
+	engine.AddMiddlewareFunc(trace("engine1"), trace("engine2"))
+	g := engine.Group("user")
+	g.AddMiddlewareFunc(trace("group"))
+	g.Get("/info", func(ctx *Context) {
+		order = append(order, "handler")
+	}, trace("route"))
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/info", nil))
+	want := "[engine1 before engine2 before group before route before handler route after group after engine2 after engine1 after]"
+	if fmt.Sprint(order) != want {
+		t.Errorf("got %v, want %s", order, want)
+	}
+}
+
+func TestMiddlewareAbort(t *testing.T) {
+	engine := New()
+	aborted := false
+	handled := false
+	engine.AddMiddlewareFunc(Middleware(func(ctx *Context) {
+		ctx.Next()
+		aborted = ctx.IsAborted()
+	}))
+	engine.AddMiddlewareFunc(Middleware(func(ctx *Context) {
+		ctx.AbortWithStatus(http.StatusForbidden)
+	}))
+	engine.Group("user").Get("/info", func(ctx *Context) {
+		handled = true
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/info", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("got %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if handled {
+		t.Error("handler executed after Abort")
+	}
+	if !aborted {
+		t.Error("outer middleware did not observe Abort")
+	}
+}
+
+func TestAutoMethods(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	g.Get("/info", func(ctx *Context) {
+		ctx.W.Header().Set("X-Handler", "get")
+		ctx.String(http.StatusOK, "info")
+	})
+	g.Post("/info", func(ctx *Context) {})
+	engine.SetNotFoundHandler(func(ctx *Context) {
+		ctx.String(http.StatusNotFound, "custom not found")
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		allow  string
+		body   string
+	}{
+		{http.MethodHead, "/user/info", http.StatusOK, "", ""},
+		{http.MethodOptions, "/user/info", http.StatusNoContent, "GET, HEAD, OPTIONS, POST", ""},
+		{http.MethodDelete, "/user/info", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS, POST", `{"title":"Method Not Allowed","status":405,"detail":"DELETE /user/info not allowed","instance":"/user/info"}`},
+		{http.MethodGet, "/user/none", http.StatusNotFound, "", "custom not found"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.code || w.Header().Get("Allow") != tt.allow || w.Body.String() != tt.body {
+			t.Errorf("%s %s: got %d Allow=%q %q, want %d Allow=%q %q",
+				tt.method, tt.path, w.Code, w.Header().Get("Allow"), w.Body.String(), tt.code, tt.allow, tt.body)
+		}
+	}
+}
+
+func TestWebSocketRoute(t *testing.T) {
+	engine := New()
+	authed := false
+	engine.Group("ws").WebSocket("/orders/:id", func(ctx *Context, conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(ctx.Param("id")))
+	}, func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			authed = true
+			next(ctx)
+		}
+	})
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	netConn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer netConn.Close()
+	fmt.Fprint(netConn, "GET /ws/orders/7 HTTP/1.1\r\nHost: "+server.Listener.Addr().String()+
+		"\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols || !authed {
+		t.Fatalf("handshake: got %v %v authed=%v", resp, err, authed)
+	}
+	// 服务端的帧不掩码 0x81为单帧文本消息
+	frame := make([]byte, 3)
+	if _, err := io.ReadFull(br, frame); err != nil || string(frame) != "\x81\x017" {
+		t.Errorf("frame: got %q %v", frame, err)
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws/orders/7", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("plain GET: got %d", w.Code)
+	}
+}
+
+type stockError struct{}
+
+func (stockError) Error() string   { return "out of stock" }
+func (stockError) StatusCode() int { return http.StatusConflict }
+
+func TestErrorPipeline(t *testing.T) {
+	engine := New()
+	engine.AddMiddlewareFunc(Recovery)
+	g := engine.Group("order")
+	g.Get("/panic", func(ctx *Context) {
+		panic("boom")
+	})
+	g.Get("/missing", func(ctx *Context) {
+		ctx.Error(NewProblem(http.StatusNotFound, "order 7 not found").With("order_id", 7))
+	})
+	g.Get("/stock", func(ctx *Context) {
+		ctx.Error(fmt.Errorf("create order: %w", stockError{}))
+	})
+	accounts := &Accounts{Users: map[string]string{"admin": "123"}, Realm: "Basic"}
+	g.Get("/admin", func(ctx *Context) {}, accounts.BasicAuth)
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/order/panic", http.StatusInternalServerError, `{"title":"Internal Server Error","status":500,"instance":"/order/panic"}`},
+		{"/order/missing", http.StatusNotFound, `{"title":"Not Found","status":404,"detail":"order 7 not found","instance":"/order/missing","order_id":7}`},
+		{"/order/stock", http.StatusConflict, `{"title":"Conflict","status":409,"detail":"create order: out of stock","instance":"/order/stock"}`},
+		{"/order/admin", http.StatusUnauthorized, `{"title":"Unauthorized","status":401,"instance":"/order/admin"}`},
+		{"/order/none", http.StatusNotFound, `{"title":"Not Found","status":404,"detail":"/order/none not found","instance":"/order/none"}`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code || w.Body.String() != tt.body || w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%s: got %d %s", tt.path, w.Code, w.Body.String())
+		}
+	}
+
+	// 自定义的错误处理 返回的数据按JSON输出
+	engine.RegisterErrorHandler(func(err error) (int, any) {
+		return http.StatusOK, map[string]string{"msg": err.Error()}
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/order/stock", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"msg":"create order: out of stock"}` {
+		t.Errorf("custom handler: got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandlerWithError(t *testing.T) {
+	type createReq struct {
+		UserID int    `uri:"uid" json:"-"`
+		SkuID  int    `json:"sku_id" validate:"required"`
+		Remark string `json:"remark"`
+	}
+	type order struct {
+		UserID int `json:"user_id"`
+		SkuID  int `json:"sku_id"`
+	}
+	engine := New()
+	g := engine.Group("orders")
+	g.HandleWithError(http.MethodPost, "/:uid", Typed(func(ctx *Context, req *createReq) (*order, error) {
+		if req.SkuID == 404 {
+			return nil, NewProblem(http.StatusNotFound, "sku not found")
+		}
+		return &order{UserID: req.UserID, SkuID: req.SkuID}, nil
+	}))
+	g.Get("/ping", WithError(func(ctx *Context) error {
+		return errors.New("db down")
+	}))
+	g.HandleWithError(http.MethodGet, "/search", Typed(func(ctx *Context, req struct {
+		Page int `form:"page" default:"1"`
+	}) (int, error) {
+		return req.Page, nil
+	}))
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+		resp   string
+	}{
+		{http.MethodPost, "/orders/7", `{"sku_id":3}`, http.StatusOK, `{"user_id":7,"sku_id":3}`},
+		{http.MethodPost, "/orders/7", `{"sku_id":404}`, http.StatusNotFound, `{"title":"Not Found","status":404,"detail":"sku not found","instance":"/orders/7"}`},
+		{http.MethodPost, "/orders/7", `{"sku_id":"x"}`, http.StatusBadRequest, ""},
+		{http.MethodGet, "/orders/ping", "", http.StatusInternalServerError, `{"title":"Internal Server Error","status":500,"instance":"/orders/ping"}`},
+		{http.MethodGet, "/orders/search", "", http.StatusOK, "1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code || (tt.resp != "" && w.Body.String() != tt.resp) {
+			t.Errorf("%s %s %s: got %d %s", tt.method, tt.path, tt.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	engine := New()
+	g := engine.Group("orders")
+	g.AddMiddlewareFunc(RateLimit(RateLimitOptions{
+		Rule: ratelimit.Rule{Algorithm: ratelimit.FixedWindow, Limit: 2, Window: time.Minute},
+		Routes: map[string]ratelimit.Rule{
+			"POST /orders/:id": {Algorithm: ratelimit.FixedWindow, Limit: 1, Window: time.Minute},
+		},
+	}))
+	g.Get("/:id", func(ctx *Context) {})
+	g.Post("/:id", func(ctx *Context) {})
+
+	tests := []struct {
+		method string
+		addr   string
+		code   int
+	}{
+		{http.MethodGet, "10.0.0.1:1000", http.StatusOK},
+		{http.MethodGet, "10.0.0.1:1001", http.StatusOK},
+		{http.MethodGet, "10.0.0.1:1002", http.StatusTooManyRequests},
+		// 其他IP单独计数
+		{http.MethodGet, "10.0.0.2:1000", http.StatusOK},
+		// 覆盖规则的路由单独计数
+		{http.MethodPost, "10.0.0.1:1000", http.StatusOK},
+		{http.MethodPost, "10.0.0.1:1000", http.StatusTooManyRequests},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/orders/7", nil)
+		r.RemoteAddr = tt.addr
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Header().Get("X-RateLimit-Limit") == "" {
+			t.Errorf("step %d: got %d %v", i, w.Code, w.Header())
+		}
+		if tt.code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("step %d: missing Retry-After", i)
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	engine := New()
+	engine.AddMiddlewareFunc(CORS(CORSOptions{
+		AllowOrigins:     []string{"https://mall.example.com", "https://*.shop.example.com"},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Request-Id"},
+		MaxAge:           10 * time.Minute,
+	}))
+	g := engine.Group("orders")
+	g.Post("/create", func(ctx *Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	// 只注册了POST 预检由路由自动应答
+	r := httptest.NewRequest(http.MethodOptions, "/orders/create", nil)
+	r.Header.Set("Origin", "https://m.shop.example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	r.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	h := w.Header()
+	if w.Code != http.StatusNoContent || h.Get("Access-Control-Allow-Origin") != "https://m.shop.example.com" ||
+		h.Get("Access-Control-Allow-Methods") != "OPTIONS, POST" || h.Get("Access-Control-Allow-Headers") != "Content-Type" ||
+		h.Get("Access-Control-Allow-Credentials") != "true" || h.Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("preflight: got %d %v", w.Code, h)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/orders/create", nil)
+	r.Header.Set("Origin", "https://mall.example.com")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	if w.Body.String() != "ok" || w.Header().Get("Access-Control-Allow-Origin") != "https://mall.example.com" ||
+		w.Header().Get("Access-Control-Expose-Headers") != "X-Request-Id" {
+		t.Errorf("request: got %d %v", w.Code, w.Header())
+	}
+
+	r = httptest.NewRequest(http.MethodOptions, "/orders/create", nil)
+	r.Header.Set("Origin", "https://evil.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed origin: got %d %v", w.Code, w.Header())
+	}
+
+	// 路径不存在 预检返回404
+	r = httptest.NewRequest(http.MethodOptions, "/orders/missing", nil)
+	r.Header.Set("Origin", "https://mall.example.com")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound || w.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("unknown path: got %d %v", w.Code, w.Header())
+	}
+
+	// 任何网站都能读取带Cookie的响应
+	defer func() {
+		if recover() == nil {
+			t.Error("wildcard origin with credentials should panic")
+		}
+	}()
+	CORS(CORSOptions{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
+
+func TestClientIP(t *testing.T) {
+	engine := New()
+	if err := engine.SetTrustedProxies([]string{"10.0.0.0/8", "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		remote string
+		header string
+		value  string
+		want   string
+	}{
+		// 不可信的连接 忽略请求头
+		{"1.2.3.4:1000", "X-Forwarded-For", "9.9.9.9", "1.2.3.4"},
+		{"127.0.0.1:1000", "X-Forwarded-For", "9.9.9.9, 8.8.8.8, 10.0.0.2", "8.8.8.8"},
+		{"127.0.0.1:1000", "X-Real-IP", "8.8.8.8", "8.8.8.8"},
+		{"127.0.0.1:1000", "Forwarded", `for=8.8.8.8;proto=https, for="[2001:db8::1]:4711"`, "2001:db8::1"},
+		{"10.0.0.1:1000", "X-Forwarded-For", "10.0.0.3", "10.0.0.3"},
+		{"10.0.0.1:1000", "X-Forwarded-For", "bad", "10.0.0.1"},
+		// 客户端伪造的部分不合法 代理追加的真实地址依然有效
+		{"10.0.0.1:1000", "X-Forwarded-For", "x, 1.2.3.4", "1.2.3.4"},
+		{"10.0.0.1:1000", "X-Forwarded-For", "1.2.3.4, x, 10.0.0.2", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		r.Header.Set(tt.header, tt.value)
+		ctx := &Context{engine: engine, R: r}
+		if got := ctx.ClientIP(); got != tt.want {
+			t.Errorf("%s %s=%s: got %s want %s", tt.remote, tt.header, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	// 下游服务 返回收到的请求ID
+	downstream := New()
+	downstream.AddMiddlewareFunc(RequestID)
+	downstream.Group("goods").Get("/find", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.RequestID())
+	})
+	server := httptest.NewServer(downstream)
+	defer server.Close()
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	client := rpc.NewHttpClient()
+	client.RegisterHttpService("goods", &goodsService{config: rpc.HttpConfig{Host: addr.IP.String(), Port: addr.Port}})
+	session := client.Session()
+	engine := New()
+	engine.AddMiddlewareFunc(RequestID)
+	engine.Group("orders").Get("/find", func(ctx *Context) {
+		body, err := session.WithContext(ctx.R.Context()).Do("goods", "Find").(*goodsService).Find(nil)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.String(http.StatusOK, ctx.RequestID()+" "+string(body))
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/orders/find", nil)
+	r.Header.Set("X-Request-ID", "abc-123")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	if w.Body.String() != "abc-123 abc-123" || w.Header().Get("X-Request-ID") != "abc-123" {
+		t.Errorf("propagate: got %s %v", w.Body.String(), w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/find", nil))
+	id := w.Header().Get("X-Request-ID")
+	if len(id) != 32 || w.Body.String() != id+" "+id {
+		t.Errorf("generate: got %s %q", w.Body.String(), id)
+	}
+
+	// 并发请求各自转发自己的请求ID
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/orders/find", nil)
+			r.Header.Set("X-Request-ID", id)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, r)
+			if w.Body.String() != id+" "+id {
+				t.Errorf("concurrent %s: got %s", id, w.Body.String())
+			}
+		}(fmt.Sprintf("req-%d", i))
+	}
+	wg.Wait()
+}
+
+type goodsService struct {
+	config rpc.HttpConfig
+	Find   func(args map[string]any) ([]byte, error) `geerpc:"GET,/goods/find"`
+}
+
+func (s *goodsService) Env() rpc.HttpConfig {
+	return s.config
+}
+
+func TestAddMiddlewareAfterServing(t *testing.T) {
+	engine := New()
+	group := engine.Group("user")
+	group.Get("/info", func(ctx *Context) {})
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/info", nil))
+	for name, add := range map[string]func(){
+		"engine": func() { engine.AddMiddlewareFunc(Logging) },
+		"group":  func() { group.AddMiddlewareFunc(Logging) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: adding middleware after serving should panic", name)
+				}
+			}()
+			add()
+		}()
+	}
+}
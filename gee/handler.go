@@ -0,0 +1,73 @@
+package gee
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	"github.com/gee-coder/gee/binding"
+)
+
+// HandlerFuncWithError 返回error的处理方法 错误交给Engine的错误处理方法生成响应
+type HandlerFuncWithError func(ctx *Context) error
+
+// WithError 转换成HandlerFunc 可以用在Get、Post等注册方法中
+func WithError(h HandlerFuncWithError) HandlerFunc {
+	return func(ctx *Context) {
+		if err := h(ctx); err != nil {
+			ctx.Error(err)
+		}
+	}
+}
+
+// HandleWithError 注册返回error的处理方法 method可以是ANY
+func (r *routerGroup) HandleWithError(method string, routerName string, handler HandlerFuncWithError, middlewareFunc ...MiddlewareFunc) {
+	r.handle(routerName, method, WithError(handler), middlewareFunc...)
+}
+
+// Typed 自动绑定请求参数并把返回值按JSON输出
+// 先绑定路由参数 再按请求方法和Content-Type绑定 绑定失败返回400
+// 处理方法已经写入响应时不再输出返回值
+//
+//	group.HandleWithError(http.MethodPost, "/create", gee.Typed(func(ctx *gee.Context, req CreateOrderReq) (*Order, error) {...}))
+func Typed[Req, Resp any](h func(ctx *Context, req Req) (Resp, error)) HandlerFuncWithError {
+	return func(ctx *Context) error {
+		var req Req
+		if err := ctx.bindTyped(&req); err != nil {
+			return err
+		}
+		resp, err := h(ctx, req)
+		if err != nil {
+			return err
+		}
+		if ctx.W.Written() {
+			return nil
+		}
+		return ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+// req为*Req Req本身是指针时先分配
+func (c *Context) bindTyped(req any) error {
+	value := reflect.ValueOf(req).Elem()
+	if value.Kind() == reflect.Pointer {
+		value.Set(reflect.New(value.Type().Elem()))
+		req = value.Interface()
+		value = value.Elem()
+	}
+	// 没有字段的结构体不需要绑定
+	if value.Kind() != reflect.Struct || value.NumField() == 0 {
+		return nil
+	}
+	if len(c.params) > 0 {
+		// 必填校验放到下面的完整绑定中
+		var errs binding.ValidationErrors
+		if err := c.ShouldBindUri(req); err != nil && !errors.As(err, &errs) {
+			return c.bindError(err)
+		}
+	}
+	if err := c.ShouldBind(req); err != nil {
+		return c.bindError(err)
+	}
+	return nil
+}
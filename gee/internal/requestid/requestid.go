@@ -0,0 +1,19 @@
+package requestid
+
+import "context"
+
+// Header 传递请求ID的请求头
+const Header = "X-Request-ID"
+
+type ctxKey struct{}
+
+// NewContext 保存请求ID 供rpc客户端转发给下游服务
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext 取出请求ID 没有时为空字符串
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
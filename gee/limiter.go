@@ -8,16 +8,20 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// Limiter 所有请求共用一个令牌桶 最多等待1秒
+//
+// Deprecated: 使用 RateLimit 按客户端限流
 func Limiter(limit, cap int) MiddlewareFunc {
 	li := rate.NewLimiter(rate.Limit(limit), cap)
 	return func(next HandlerFunc) HandlerFunc {
 		return func(ctx *Context) {
 			// 实现限流
-			con, cancel := context.WithTimeout(context.Background(), time.Duration(1)*time.Second)
+			con, cancel := context.WithTimeout(ctx.R.Context(), time.Duration(1)*time.Second)
 			defer cancel()
 			err := li.WaitN(con, 1)
 			if err != nil {
-				ctx.String(http.StatusForbidden, "限流了")
+				ctx.Abort()
+				ctx.Error(NewProblem(http.StatusTooManyRequests, "rate limit exceeded"))
 				return
 			}
 			next(ctx)
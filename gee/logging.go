@@ -7,7 +7,6 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 )
 
@@ -38,6 +37,8 @@ type LogFormatterParams struct {
 	Method         string
 	Path           string
 	IsDisplayColor bool
+	// RequestID 中间件设置的请求ID
+	RequestID string
 }
 
 func (p *LogFormatterParams) StatusCodeColor() string {
@@ -50,6 +51,13 @@ func (p *LogFormatterParams) StatusCodeColor() string {
 	}
 }
 
+func (p *LogFormatterParams) requestIDField() string {
+	if p.RequestID == "" {
+		return ""
+	}
+	return " | request_id=" + p.RequestID
+}
+
 type LoggerFormatter = func(params *LogFormatterParams) string
 
 var defaultFormatter = func(params *LogFormatterParams) string {
@@ -57,19 +65,21 @@ var defaultFormatter = func(params *LogFormatterParams) string {
 		params.Latency = params.Latency.Truncate(time.Second)
 	}
 	if params.IsDisplayColor {
-		return fmt.Sprintf("%s[gee]%s|%s%v%s|%s%3d%s| %s%13v%s | %15s | %s %-7s %s %s %#v %s \n",
+		return fmt.Sprintf("%s[gee]%s|%s%v%s|%s%3d%s| %s%13v%s | %15s | %s %-7s %s %s %#v %s%s\n",
 			yellow, reset, blue, params.TimeStamp.Format("2006-01-02 15:04:05"), reset,
 			params.StatusCodeColor(), params.StatusCode, reset,
 			red, params.Latency, reset,
 			params.ClientIP,
 			magenta, params.Method, reset,
 			cyan, params.Path, reset,
+			params.requestIDField(),
 		)
 	}
-	return fmt.Sprintf("[gee] %v | %3d | %13v | %15s |%-7s %#v",
+	return fmt.Sprintf("[gee] %v | %3d | %13v | %15s |%-7s %#v%s",
 		params.TimeStamp.Format("2006-01-02 15:04:05"),
 		params.StatusCode,
 		params.Latency, params.ClientIP, params.Method, params.Path,
+		params.requestIDField(),
 	)
 }
 
@@ -105,10 +115,10 @@ func LoggingWithConfig(conf LoggingConfig, next HandlerFunc) HandlerFunc {
 		next(ctx)
 		param.TimeStamp = time.Now()
 		param.Latency = time.Now().Sub(start)
-		ip, _, _ := net.SplitHostPort(strings.TrimSpace(ctx.R.RemoteAddr))
-		param.ClientIP = net.ParseIP(ip)
+		param.ClientIP = net.ParseIP(ctx.ClientIP())
+		param.RequestID = ctx.RequestID()
 		param.Method = r.Method
-		param.StatusCode = ctx.StatusCode
+		param.StatusCode = ctx.W.Status()
 		_, err := fmt.Fprint(out, formatter(param))
 		if err != nil {
 			log.Println(err)
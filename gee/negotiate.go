@@ -0,0 +1,136 @@
+package gee
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gee-coder/gee/binding"
+	"github.com/gee-coder/gee/render"
+)
+
+// Negotiate 内容协商 根据Accept从Offered中选出响应格式
+// 各格式的数据为空时使用Data
+type Negotiate struct {
+	Offered      []string
+	HTMLName     string
+	HTMLData     any
+	JSONData     any
+	XMLData      any
+	YAMLData     any
+	ProtoBufData any
+	MsgPackData  any
+	Data         any
+}
+
+// Accept中的一项 text/html;q=0.9
+type acceptSpec struct {
+	mime string
+	q    float64
+}
+
+func parseAccept(accept string) []acceptSpec {
+	specs := make([]acceptSpec, 0)
+	for _, part := range strings.Split(accept, ",") {
+		mime, params, _ := strings.Cut(part, ";")
+		mime = strings.ToLower(strings.TrimSpace(mime))
+		if mime == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(key) != "q" {
+				continue
+			}
+			if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = f
+			}
+		}
+		// q=0 表示不接受
+		if q <= 0 {
+			continue
+		}
+		specs = append(specs, acceptSpec{mime: mime, q: q})
+	}
+	// q相同时 具体类型优先于 text/* 优先于 */*
+	sort.SliceStable(specs, func(i, j int) bool {
+		if specs[i].q != specs[j].q {
+			return specs[i].q > specs[j].q
+		}
+		return acceptSpecificity(specs[i].mime) > acceptSpecificity(specs[j].mime)
+	})
+	return specs
+}
+
+func acceptSpecificity(mime string) int {
+	switch {
+	case mime == "*/*":
+		return 0
+	case strings.HasSuffix(mime, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func acceptMatch(accepted, offered string) bool {
+	offered = strings.ToLower(offered)
+	if accepted == "*/*" || accepted == offered {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(accepted, "/*"); ok {
+		return strings.HasPrefix(offered, prefix+"/")
+	}
+	return false
+}
+
+// NegotiateFormat 返回offered中客户端最想要的格式 都不接受时返回空字符串
+// 没有Accept时返回第一个
+func (c *Context) NegotiateFormat(offered ...string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+	accept := c.R.Header.Get("Accept")
+	if accept == "" {
+		return offered[0]
+	}
+	for _, spec := range parseAccept(accept) {
+		for _, offer := range offered {
+			if acceptMatch(spec.mime, offer) {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+// Negotiate 根据Accept选择JSON、XML、YAML、ProtoBuf、MsgPack、HTML中的一种渲染
+// 没有可接受的格式时返回406
+func (c *Context) Negotiate(code int, config Negotiate) error {
+	switch c.NegotiateFormat(config.Offered...) {
+	case binding.MIMEJSON:
+		return c.Render(&render.JSON{Data: chooseData(config.JSONData, config.Data)}, code)
+	case binding.MIMEXML, binding.MIMEXML2:
+		return c.Render(&render.XML{Data: chooseData(config.XMLData, config.Data)}, code)
+	case binding.MIMEYAML, binding.MIMEYAML2:
+		return c.Render(&render.YAML{Data: chooseData(config.YAMLData, config.Data)}, code)
+	case binding.MIMEPROTOBUF:
+		return c.Render(&render.ProtoBuf{Data: chooseData(config.ProtoBufData, config.Data)}, code)
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		return c.Render(&render.MsgPack{Data: chooseData(config.MsgPackData, config.Data)}, code)
+	case binding.MIMEHTML:
+		return c.templateHTML(code, config.HTMLName, chooseData(config.HTMLData, config.Data))
+	default:
+		c.AbortWithStatus(http.StatusNotAcceptable)
+		return nil
+	}
+}
+
+func chooseData(custom, wildcard any) any {
+	if custom != nil {
+		return custom
+	}
+	return wildcard
+}
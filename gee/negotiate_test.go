@@ -0,0 +1,94 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gee-coder/gee/binding"
+)
+
+func TestNegotiate(t *testing.T) {
+	engine := New()
+	engine.Group("user").Get("/info", func(ctx *Context) {
+		ctx.Negotiate(http.StatusOK, Negotiate{
+			Offered: []string{binding.MIMEJSON, binding.MIMEXML, binding.MIMEYAML2, binding.MIMEMSGPACK},
+			Data:    map[string]string{"name": "gee"},
+			XMLData: struct {
+				Name string `xml:"name"`
+			}{"gee"},
+		})
+	})
+
+	tests := []struct {
+		accept      string
+		code        int
+		contentType string
+	}{
+		{"", http.StatusOK, "application/json; charset=utf-8"},
+		{"application/xml", http.StatusOK, "application/xml; charset=utf-8"},
+		{"text/html;q=0.9, application/yaml", http.StatusOK, "application/yaml; charset=utf-8"},
+		{"application/json;q=0.5, application/x-msgpack", http.StatusOK, "application/msgpack"},
+		{"application/*;q=0.8, */*;q=0.9", http.StatusOK, "application/json; charset=utf-8"},
+		{"application/json;q=0, application/xml;q=0.1", http.StatusOK, "application/xml; charset=utf-8"},
+		{"text/html", http.StatusNotAcceptable, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/user/info", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("Accept %q: got %d %q, want %d %q", tt.accept, w.Code, w.Header().Get("Content-Type"), tt.code, tt.contentType)
+		}
+	}
+}
+
+func TestJSONVariants(t *testing.T) {
+	engine := New()
+	group := engine.Group("json")
+	group.Get("/secure", func(ctx *Context) {
+		ctx.SecureJSON(http.StatusOK, []int{1, 2})
+	})
+	group.Get("/ascii", func(ctx *Context) {
+		ctx.AsciiJSON(http.StatusOK, map[string]string{"lang": "GO语言"})
+	})
+	group.Get("/jsonp", func(ctx *Context) {
+		ctx.JSONP(http.StatusOK, map[string]int{"a": 1})
+	})
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/json/secure", "while(1);[1,2]"},
+		{"/json/ascii", `{"lang":"GO\u8bed\u8a00"}`},
+		{"/json/jsonp?callback=jQuery1.cb", `/**/jQuery1.cb({"a":1});`},
+		{"/json/jsonp", `{"a":1}`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Body.String() != tt.body {
+			t.Errorf("%s: got %q, want %q", tt.path, w.Body.String(), tt.body)
+		}
+	}
+
+	// 不合法的回调名可以注入脚本
+	for _, callback := range []string{"alert(document.cookie);x", "cb<script>", "1cb", "a..b", "cb%0a"} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/json/jsonp?callback="+url.QueryEscape(callback), nil))
+		if w.Code != http.StatusBadRequest || strings.Contains(w.Body.String(), "(") {
+			t.Errorf("callback %q: got %d %s", callback, w.Code, w.Body.String())
+		}
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/json/jsonp?callback=cb", nil))
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("missing nosniff: %v", w.Header())
+	}
+}
@@ -1,81 +1,249 @@
 package gee
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 const SEPARATOR = "/"
 
+// 路由参数 /user/:id 中的 id
+type Param struct {
+	Key   string
+	Value string
+}
+
+// 路由参数列表 按路由中出现的顺序保存
+type Params []Param
+
+// Get 返回第一个名称为key的参数值
+func (ps Params) Get(key string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// ByName 返回第一个名称为key的参数值 不存在返回空字符串
+func (ps Params) ByName(key string) string {
+	value, _ := ps.Get(key)
+	return value
+}
+
+// 节点类型 同一层级按 静态 > 参数 > 通配 > 全匹配 的优先级匹配
+type nodeType uint8
+
+const (
+	// /user
+	staticNode nodeType = iota
+	// /:id
+	paramNode
+	// /*
+	wildcardNode
+	// /** 或 /*filepath
+	catchAllNode
+)
+
+func getNodeType(nodeName string) nodeType {
+	switch {
+	case len(nodeName) > 1 && nodeName[0] == ':':
+		return paramNode
+	case nodeName == "*":
+		return wildcardNode
+	case len(nodeName) > 1 && nodeName[0] == '*':
+		return catchAllNode
+	default:
+		return staticNode
+	}
+}
+
+// 路由树叶子节点保存的路由信息
+type route struct {
+	group *routerGroup
+	// 组下的路由 /get/:id
+	routerName string
+}
+
+// 压缩前缀树(radix tree) 每种请求方法一棵
+// 静态节点合并公共前缀 如 /user/get 和 /user/getAll 共用 /user/get
+// 参数、通配、全匹配节点只能出现在一段路径的开头 单独保存 不参与前缀合并
 type treeNode struct {
-	nodeName   string
-	children   []*treeNode
+	// 静态节点为压缩后的路径片段 其他节点为 :id * ** *filepath
+	nodeName string
+	nodeType nodeType
+	// 静态子节点路径的首字节 与children一一对应
+	indices  string
+	children []*treeNode
+	// 动态子节点 每种最多一个
+	paramChild    *treeNode
+	wildcardChild *treeNode
+	catchAllChild *treeNode
+	// 完整的路由 /user/get/:id
 	routerName string
-	isEnd      bool
+	route      *route
+}
+
+// 路由拆分成静态片段和动态片段
+// /user/:id/info -> /user/ :id /info
+func splitRouter(path string) []string {
+	tokens := make([]string, 0)
+	start := 0
+	for i := 0; i < len(path); i++ {
+		if path[i] != '/' || i+1 >= len(path) {
+			continue
+		}
+		end := strings.IndexByte(path[i+1:], '/')
+		if end < 0 {
+			end = len(path)
+		} else {
+			end += i + 1
+		}
+		segment := path[i+1 : end]
+		if getNodeType(segment) == staticNode {
+			continue
+		}
+		tokens = append(tokens, path[start:i+1], segment)
+		start = end
+		i = end - 1
+	}
+	if start < len(path) {
+		tokens = append(tokens, path[start:])
+	}
+	return tokens
 }
 
 // Put path: /user/get/:id
-func (t *treeNode) Put(path string) {
-	root := t
-	strs := strings.Split(path, SEPARATOR)
-	for index, nodeName := range strs {
-		if index == 0 {
+// 同一位置出现名称不同的参数或全匹配节点时返回错误 如 /a/:x 与 /a/:y
+func (t *treeNode) Put(path string, r *route) error {
+	tokens := splitRouter(path)
+	n := t
+	for index, token := range tokens {
+		typ := getNodeType(token)
+		if typ == staticNode {
+			n = n.putStatic(token)
 			continue
 		}
-		children := t.children
-		isMatch := false
-		for _, node := range children {
-			if node.nodeName == nodeName {
-				isMatch = true
-				t = node
-				break
-			}
+		if typ == catchAllNode && index != len(tokens)-1 {
+			return fmt.Errorf("[路由：%s]中的%s只能出现在末尾", path, token)
 		}
-		if !isMatch {
-			isEnd := false
-			if index == len(strs)-1 {
-				isEnd = true
+		child := n.dynamicChild(typ)
+		if *child == nil {
+			*child = &treeNode{nodeName: token, nodeType: typ, routerName: path}
+		} else if (*child).nodeName != token {
+			return fmt.Errorf("[路由：%s]中的%s与已注册的[路由：%s]冲突", path, token, (*child).routerName)
+		}
+		n = *child
+	}
+	if n.route != nil && (n.route.group != r.group || n.route.routerName != r.routerName) {
+		return fmt.Errorf("[路由：%s]与已注册的[路由：%s]冲突", path, n.routerName)
+	}
+	n.routerName = path
+	n.route = r
+	return nil
+}
+
+func (t *treeNode) dynamicChild(typ nodeType) **treeNode {
+	switch typ {
+	case paramNode:
+		return &t.paramChild
+	case wildcardNode:
+		return &t.wildcardChild
+	default:
+		return &t.catchAllChild
+	}
+}
+
+// 插入静态片段 返回片段结束位置的节点 必要时拆分已有节点
+func (t *treeNode) putStatic(path string) *treeNode {
+	n := t
+	for len(path) > 0 {
+		i := strings.IndexByte(n.indices, path[0])
+		if i < 0 {
+			child := &treeNode{nodeName: path}
+			n.indices += path[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+		child := n.children[i]
+		common := commonPrefix(path, child.nodeName)
+		if common < len(child.nodeName) {
+			// 拆分 原节点成为公共前缀节点的子节点
+			prefix := &treeNode{
+				nodeName: child.nodeName[:common],
+				indices:  child.nodeName[common : common+1],
+				children: []*treeNode{child},
 			}
-			node := &treeNode{nodeName: nodeName, children: make([]*treeNode, 0), routerName: t.routerName + SEPARATOR + nodeName, isEnd: isEnd}
-			children = append(children, node)
-			t.children = children
-			t = node
+			child.nodeName = child.nodeName[common:]
+			n.children[i] = prefix
+			child = prefix
 		}
+		path = path[common:]
+		n = child
 	}
-	t = root
+	return n
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
 }
 
 // Get path: /user/get/1
 // /hello
-func (t *treeNode) Get(path string) *treeNode {
-	nodeNames := strings.Split(path, SEPARATOR)
-	for index, nodeName := range nodeNames {
-		if index == 0 {
-			continue
-		}
-		children := t.children
-		isMatch := false
-		for _, node := range children {
-			if node.nodeName == nodeName ||
-				node.nodeName == "*" ||
-				strings.Contains(node.nodeName, ":") {
-				isMatch = true
-				if index == len(nodeNames)-1 {
-					return node
-				} else {
-					t = node
+// 返回匹配的节点和路径中解析出的参数
+func (t *treeNode) Get(path string) (*treeNode, Params) {
+	return t.match(path, nil)
+}
+
+// path为去掉当前节点后剩余的路径
+// 按优先级依次尝试子节点 子树匹配失败时回溯尝试下一个
+func (t *treeNode) match(path string, params Params) (*treeNode, Params) {
+	if path == "" && t.route != nil {
+		return t, params
+	}
+	if path != "" {
+		if i := strings.IndexByte(t.indices, path[0]); i >= 0 {
+			child := t.children[i]
+			if strings.HasPrefix(path, child.nodeName) {
+				if n, ps := child.match(path[len(child.nodeName):], params); n != nil {
+					return n, ps
 				}
-				break
 			}
 		}
-		if !isMatch {
-			for _, node := range children {
-				// /user/**
-				// /user/get/userInfo
-				// /user/aa/bb
-				if node.nodeName == "**" {
-					return node
-				}
+	}
+	// 动态节点只会挂在以 / 结尾的静态节点下
+	end := strings.IndexByte(path, '/')
+	if end < 0 {
+		end = len(path)
+	}
+	if end > 0 {
+		if t.paramChild != nil {
+			ps := append(params[:len(params):len(params)], Param{Key: t.paramChild.nodeName[1:], Value: path[:end]})
+			if n, ps := t.paramChild.match(path[end:], ps); n != nil {
+				return n, ps
+			}
+		}
+		if t.wildcardChild != nil {
+			if n, ps := t.wildcardChild.match(path[end:], params); n != nil {
+				return n, ps
 			}
-
 		}
 	}
-	return nil
+	if t.catchAllChild != nil {
+		// /user/**
+		// /user/get/userInfo
+		// /static/*filepath
+		// /static/css/main.css filepath=/css/main.css
+		ps := params
+		if t.catchAllChild.nodeName != "**" {
+			ps = append(params[:len(params):len(params)], Param{Key: t.catchAllChild.nodeName[1:], Value: SEPARATOR + path})
+		}
+		return t.catchAllChild, ps
+	}
+	return nil, nil
 }
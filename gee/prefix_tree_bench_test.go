@@ -0,0 +1,162 @@
+package gee
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// 替换前的实现 每个组一棵按段切分的前缀树 请求时逐个组扫描 仅用于基准对比
+type legacyTreeNode struct {
+	nodeName   string
+	children   []*legacyTreeNode
+	routerName string
+	isEnd      bool
+}
+
+func (t *legacyTreeNode) Put(path string) {
+	strs := strings.Split(path, SEPARATOR)
+	for index, nodeName := range strs {
+		if index == 0 {
+			continue
+		}
+		isMatch := false
+		for _, node := range t.children {
+			if node.nodeName == nodeName {
+				isMatch = true
+				t = node
+				break
+			}
+		}
+		if !isMatch {
+			node := &legacyTreeNode{nodeName: nodeName, routerName: t.routerName + SEPARATOR + nodeName, isEnd: index == len(strs)-1}
+			t.children = append(t.children, node)
+			t = node
+		}
+	}
+}
+
+func (t *legacyTreeNode) Get(path string) *legacyTreeNode {
+	nodeNames := strings.Split(path, SEPARATOR)
+	for index, nodeName := range nodeNames {
+		if index == 0 {
+			continue
+		}
+		isMatch := false
+		for _, node := range t.children {
+			if node.nodeName == nodeName || node.nodeName == "*" || strings.Contains(node.nodeName, ":") {
+				isMatch = true
+				if index == len(nodeNames)-1 {
+					return node
+				}
+				t = node
+				break
+			}
+		}
+		if !isMatch {
+			for _, node := range t.children {
+				if node.nodeName == "**" {
+					return node
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type legacyGroup struct {
+	groupName  string
+	treeNode   *legacyTreeNode
+	handlerMap map[string]map[string]HandlerFunc
+}
+
+type legacyRouter []*legacyGroup
+
+func (r legacyRouter) lookup(method, path string) HandlerFunc {
+	for _, group := range r {
+		routerName := SubStringLast(path, "/"+group.groupName)
+		node := group.treeNode.Get(routerName)
+		if node != nil && node.isEnd {
+			if h, ok := group.handlerMap[node.routerName][ANY]; ok {
+				return h
+			}
+			return group.handlerMap[node.routerName][method]
+		}
+	}
+	return nil
+}
+
+const (
+	benchGroups      = 20
+	benchGroupRoutes = 60
+)
+
+// 20个组 每组60个路由 共1200个 静态路由与参数路由各半
+func benchRoutes() (groups []string, routes []string) {
+	for g := 0; g < benchGroups; g++ {
+		groups = append(groups, fmt.Sprintf("service%d", g))
+	}
+	for r := 0; r < benchGroupRoutes/2; r++ {
+		routes = append(routes, fmt.Sprintf("/resource%d/list", r), fmt.Sprintf("/resource%d/:id/detail", r))
+	}
+	return
+}
+
+// 每个组取首、中、尾各一条 静态与参数路由都覆盖
+func benchPaths(groups []string) []string {
+	paths := make([]string, 0)
+	for _, g := range []string{groups[0], groups[len(groups)/2], groups[len(groups)-1]} {
+		for _, r := range []int{0, benchGroupRoutes / 4, benchGroupRoutes/2 - 1} {
+			paths = append(paths, fmt.Sprintf("/%s/resource%d/list", g, r), fmt.Sprintf("/%s/resource%d/42/detail", g, r))
+		}
+	}
+	return paths
+}
+
+func BenchmarkLegacyRouter(b *testing.B) {
+	groups, routes := benchRoutes()
+	router := make(legacyRouter, 0, len(groups))
+	for _, name := range groups {
+		g := &legacyGroup{groupName: name, treeNode: &legacyTreeNode{}, handlerMap: make(map[string]map[string]HandlerFunc)}
+		for _, r := range routes {
+			g.treeNode.Put(r)
+			g.handlerMap[r] = map[string]HandlerFunc{http.MethodGet: func(ctx *Context) {}}
+		}
+		router = append(router, g)
+	}
+	paths := benchPaths(groups)
+	for _, path := range paths {
+		if router.lookup(http.MethodGet, path) == nil {
+			b.Fatalf("%s: no route matched", path)
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.lookup(http.MethodGet, paths[i%len(paths)])
+	}
+}
+
+func BenchmarkRadixRouter(b *testing.B) {
+	groups, routes := benchRoutes()
+	engine := New()
+	for _, name := range groups {
+		g := engine.Group(name)
+		for _, r := range routes {
+			g.Get(r, func(ctx *Context) {})
+		}
+	}
+	paths := benchPaths(groups)
+	tree := engine.trees[http.MethodGet]
+	for _, path := range paths {
+		if node, _ := tree.Get(path); node == nil {
+			b.Fatalf("%s: no route matched", path)
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Get(paths[i%len(paths)])
+	}
+}
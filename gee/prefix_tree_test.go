@@ -5,19 +5,106 @@ import (
 	"testing"
 )
 
+func newTestTree(t testing.TB, paths ...string) *treeNode {
+	root := &treeNode{}
+	for _, path := range paths {
+		if err := root.Put(path, &route{routerName: path}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
 func TestTreeNode(t *testing.T) {
-	root := &treeNode{nodeName: "/", children: make([]*treeNode, 0), routerName: "/", isEnd: false}
-	root.Put("/user/get/:id")
-	root.Put("/user/create/hello")
-	root.Put("/user/create/aaa")
-	root.Put("/order/get/aaa")
-
-	node := root.Get("/user/get/:id")
-	fmt.Println(node)
-	node = root.Get("/user/create/hello")
-	fmt.Println(node)
-	node = root.Get("/user/create/aaa")
-	fmt.Println(node)
-	node = root.Get("/order/get/aaa")
-	fmt.Println(node)
+	root := newTestTree(t, "/user/get/:id", "/user/create/hello", "/user/create/aaa", "/order/get/aaa", "/user/getAll")
+
+	tests := []struct {
+		path       string
+		routerName string
+	}{
+		{"/user/get/1", "/user/get/:id"},
+		{"/user/getAll", "/user/getAll"},
+		{"/user/create/hello", "/user/create/hello"},
+		{"/user/create/aaa", "/user/create/aaa"},
+		{"/order/get/aaa", "/order/get/aaa"},
+		{"/user/create", ""},
+		{"/user/get/", ""},
+		{"/order/get/aaa/bbb", ""},
+	}
+	for _, tt := range tests {
+		node, _ := root.Get(tt.path)
+		routerName := ""
+		if node != nil {
+			routerName = node.route.routerName
+		}
+		if routerName != tt.routerName {
+			t.Errorf("%s: matched %q, want %q", tt.path, routerName, tt.routerName)
+		}
+	}
+}
+
+func TestTreeNodeParams(t *testing.T) {
+	root := newTestTree(t, "/user/:id/order/:orderId", "/static/*filepath")
+
+	tests := []struct {
+		path       string
+		routerName string
+		params     Params
+	}{
+		{"/user/1/order/2", "/user/:id/order/:orderId", Params{{"id", "1"}, {"orderId", "2"}}},
+		{"/static/css/main.css", "/static/*filepath", Params{{"filepath", "/css/main.css"}}},
+		{"/static/", "/static/*filepath", Params{{"filepath", "/"}}},
+	}
+	for _, tt := range tests {
+		node, params := root.Get(tt.path)
+		if node == nil {
+			t.Fatalf("%s: no route matched", tt.path)
+		}
+		if node.routerName != tt.routerName {
+			t.Errorf("%s: matched %s, want %s", tt.path, node.routerName, tt.routerName)
+		}
+		if fmt.Sprint(params) != fmt.Sprint(tt.params) {
+			t.Errorf("%s: params %v, want %v", tt.path, params, tt.params)
+		}
+	}
+}
+
+func TestTreeNodePriority(t *testing.T) {
+	// 注册顺序与优先级相反
+	root := newTestTree(t, "/user/**", "/user/*", "/user/:id", "/user/me", "/user/:id/info")
+
+	tests := []struct {
+		path       string
+		routerName string
+	}{
+		{"/user/me", "/user/me"},
+		{"/user/1", "/user/:id"},
+		{"/user/mex", "/user/:id"},
+		{"/user/me/info", "/user/:id/info"},
+		{"/user/1/order", "/user/**"},
+	}
+	for _, tt := range tests {
+		node, _ := root.Get(tt.path)
+		if node == nil || node.routerName != tt.routerName {
+			t.Errorf("%s: matched %v, want %s", tt.path, node, tt.routerName)
+		}
+	}
+}
+
+func TestTreeNodeConflict(t *testing.T) {
+	tests := []struct {
+		exist string
+		path  string
+	}{
+		{"/a/:x", "/a/:y"},
+		{"/a/**", "/a/*filepath"},
+		{"/a/*filepath", "/a/*name"},
+		{"/a/b", "/a/*filepath/b"},
+	}
+	for _, tt := range tests {
+		root := newTestTree(t, tt.exist)
+		if err := root.Put(tt.path, &route{routerName: tt.path}); err == nil {
+			t.Errorf("%s after %s: expected conflict", tt.path, tt.exist)
+		}
+	}
 }
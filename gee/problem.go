@@ -0,0 +1,159 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/gee-coder/gee/binding"
+	geeError "github.com/gee-coder/gee/error"
+	"github.com/gee-coder/gee/render"
+)
+
+// Problem RFC 7807 问题详情 同时也是一个error
+// 处理方法可以通过 ctx.Error(gee.NewProblem(404, "订单不存在")) 返回指定的状态码
+type Problem struct {
+	// 问题类型的URI 为空时按about:blank处理
+	Type   string `json:"type,omitempty"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// 出现问题的请求路径 为空时使用请求的路径
+	Instance string `json:"instance,omitempty"`
+	// 扩展字段 和上面的字段一起输出
+	Extensions map[string]any `json:"-"`
+	// 原始错误 不输出
+	Err error `json:"-"`
+}
+
+// NewProblem title为状态码对应的标准描述
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// With 添加扩展字段
+func (p *Problem) With(key string, value any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any)
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+// 扩展字段按名称排序追加在标准字段之后 与标准字段重名的扩展字段被忽略
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	data, err := json.Marshal((*problem)(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+	keys := make([]string, 0, len(p.Extensions))
+	for k := range p.Extensions {
+		switch k {
+		case "type", "title", "status", "detail", "instance":
+		default:
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	buf := bytes.NewBuffer(data[:len(data)-1])
+	for _, k := range keys {
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(p.Extensions[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// StatusCoder 实现了这个接口的错误按StatusCode返回状态码
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// DefaultErrorHandler 把错误转换成问题详情
+// *Problem原样返回 参数校验错误返回400并带上errors字段 实现了StatusCoder的错误使用对应的状态码
+// 其他错误返回500 不向客户端暴露错误信息
+func DefaultErrorHandler(err error) (int, any) {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem.Status, problem
+	}
+	var validationErrors binding.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		problem = NewProblem(http.StatusBadRequest, "")
+		problem.Err = err
+		return problem.Status, problem.With("errors", validationErrors)
+	}
+	var statusCoder StatusCoder
+	if errors.As(err, &statusCoder) {
+		problem = NewProblem(statusCoder.StatusCode(), err.Error())
+		problem.Err = err
+		return problem.Status, problem
+	}
+	problem = NewProblem(http.StatusInternalServerError, "")
+	problem.Err = err
+	return problem.Status, problem
+}
+
+// Error 把错误交给Engine的错误处理方法生成响应
+// 响应已经写入时只记录日志 GeeError设置了处理函数时交给处理函数
+func (c *Context) Error(err error) {
+	if err == nil {
+		return
+	}
+	c.errs = append(c.errs, err)
+	var gError *geeError.GeeError
+	if errors.As(err, &gError) && gError.ErrFuc != nil {
+		gError.ExecResult()
+		return
+	}
+	if c.W.Written() {
+		c.Logger.Error(err)
+		return
+	}
+	status, data := c.engine.errorHandler(err)
+	problem, ok := data.(*Problem)
+	if !ok {
+		c.JSON(status, data)
+		return
+	}
+	if problem.Instance == "" && c.R != nil {
+		p := *problem
+		p.Instance = c.R.URL.Path
+		problem = &p
+	}
+	c.Render(&render.ProblemJSON{Data: problem}, status)
+}
+
+// Errors 当前请求中通过Error处理过的错误 供日志等中间件使用
+func (c *Context) Errors() []error {
+	return c.errs
+}
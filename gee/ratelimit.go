@@ -0,0 +1,121 @@
+package gee
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gee-coder/gee/ratelimit"
+)
+
+// RateLimitOptions 限流中间件的配置
+type RateLimitOptions struct {
+	// 默认规则
+	Rule ratelimit.Rule
+	// 按路由覆盖默认规则 key为 "GET /user/get/:id" 或 "/user/get/:id"(所有请求方法)
+	Routes map[string]ratelimit.Rule
+	// 限流的key 默认按客户端IP 返回空字符串时不限流
+	KeyFunc func(ctx *Context) string
+	// 默认为容量10000的内存存储 多实例部署时使用共享的存储
+	Store ratelimit.Store
+	// 存储出错时是否拒绝请求 默认放行
+	FailClosed bool
+	// 返回true的请求不限流
+	Skip func(ctx *Context) bool
+}
+
+// RateLimit 按key限流 超出限制时返回429和Retry-After
+// 每个响应都带有 X-RateLimit-Limit X-RateLimit-Remaining X-RateLimit-Reset
+func RateLimit(opts RateLimitOptions) MiddlewareFunc {
+	if opts.Rule.Limit <= 0 || opts.Rule.Window <= 0 {
+		panic("gee: RateLimit rule must have a positive Limit and Window")
+	}
+	if opts.KeyFunc == nil {
+		opts.KeyFunc = KeyByIP
+	}
+	if opts.Store == nil {
+		opts.Store = ratelimit.NewMemoryStore(0)
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			if opts.Skip != nil && opts.Skip(ctx) {
+				next(ctx)
+				return
+			}
+			key := opts.KeyFunc(ctx)
+			if key == "" {
+				next(ctx)
+				return
+			}
+			rule, route := opts.rule(ctx)
+			// 覆盖规则的路由单独计数
+			if route != "" {
+				key = route + "|" + key
+			}
+			result, err := opts.Store.Take(ctx.R.Context(), key, rule)
+			if err != nil {
+				ctx.Logger.Error("rate limit: " + err.Error())
+				if opts.FailClosed {
+					ctx.Abort()
+					ctx.Error(NewProblem(http.StatusServiceUnavailable, ""))
+					return
+				}
+				next(ctx)
+				return
+			}
+			header := ctx.W.Header()
+			header.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				ctx.Abort()
+				ctx.Error(NewProblem(http.StatusTooManyRequests, "rate limit exceeded"))
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+// 当前路由的规则 使用覆盖规则时同时返回规则的key
+func (o *RateLimitOptions) rule(ctx *Context) (ratelimit.Rule, string) {
+	path := ctx.FullPath()
+	if path == "" || o.Routes == nil {
+		return o.Rule, ""
+	}
+	route := ctx.R.Method + " " + path
+	if rule, ok := o.Routes[route]; ok {
+		return rule, route
+	}
+	if rule, ok := o.Routes[path]; ok {
+		return rule, path
+	}
+	return o.Rule, ""
+}
+
+// 不足一秒按一秒算
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// KeyByIP 按客户端IP限流 在可信代理后面时使用转发的客户端IP
+func KeyByIP(ctx *Context) string {
+	return ctx.ClientIP()
+}
+
+// KeyByHeader 按请求头限流 如API Key 请求头为空时按客户端IP
+func KeyByHeader(name string) func(ctx *Context) string {
+	return func(ctx *Context) string {
+		if v := ctx.R.Header.Get(name); v != "" {
+			return name + ":" + v
+		}
+		return KeyByIP(ctx)
+	}
+}
+
+// KeyByRoute 每个路由共用一个额度 不区分客户端
+func KeyByRoute(ctx *Context) string {
+	return ctx.R.Method + " " + ctx.FullPath()
+}
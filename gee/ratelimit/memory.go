@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// 内存存储默认保存的key数量
+const defaultCapacity = 10000
+
+// MemoryStore 单实例的内存存储 key数量超过容量时淘汰最久未使用的
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	// 最近使用的在前面
+	lru *list.List
+	now func() time.Time
+}
+
+type entry struct {
+	key   string
+	state state
+	rule  Rule
+}
+
+// NewMemoryStore capacity为最多保存的key数量 不大于0时使用默认值10000
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+	return &MemoryStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, rule Rule) (Result, error) {
+	if rule.Limit <= 0 || rule.Window <= 0 {
+		return Result{}, errors.New("ratelimit: Limit and Window must be positive")
+	}
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[key]
+	if ok {
+		s.lru.MoveToFront(elem)
+	} else {
+		elem = s.lru.PushFront(&entry{key: key, rule: rule})
+		s.entries[key] = elem
+		if s.lru.Len() > s.capacity {
+			oldest := s.lru.Back()
+			s.lru.Remove(oldest)
+			delete(s.entries, oldest.Value.(*entry).key)
+		}
+	}
+	e := elem.Value.(*entry)
+	// 规则变化时重新计算
+	if e.rule != rule {
+		e.rule = rule
+		e.state = state{}
+	}
+	return e.state.take(rule, now), nil
+}
+
+// Len 当前保存的key数量
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Algorithm 限流算法
+type Algorithm int
+
+const (
+	// 令牌桶 桶容量为Limit 每Window补满一次 允许突发
+	TokenBucket Algorithm = iota
+	// 滑动窗口 按上一个窗口的请求数加权估算 窗口边界不会出现两倍流量
+	SlidingWindow
+	// 固定窗口 每个窗口最多Limit个请求
+	FixedWindow
+)
+
+// Rule 限流规则 Window内最多Limit个请求
+type Rule struct {
+	Algorithm Algorithm
+	Limit     int
+	Window    time.Duration
+}
+
+// Result 一次请求的限流结果
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// 额度完全恢复需要的时间
+	ResetAfter time.Duration
+	// 被拒绝时 再次请求前需要等待的时间
+	RetryAfter time.Duration
+}
+
+// Store 保存每个key的限流状态 多个实例共享限流时可以实现成基于Redis等的存储
+// Take 消耗key的一次额度并返回结果 实现需要保证同一个key的并发安全
+type Store interface {
+	Take(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// state 一个key的限流状态 三种算法共用
+type state struct {
+	// 令牌桶剩余的令牌 / 当前窗口的请求数
+	tokens float64
+	// 上一个窗口的请求数 滑动窗口使用
+	prev float64
+	// 令牌桶上次补充的时间 / 当前窗口的开始时间
+	last time.Time
+}
+
+// take 按规则更新状态 state为零值时表示新的key
+func (s *state) take(rule Rule, now time.Time) Result {
+	switch rule.Algorithm {
+	case SlidingWindow:
+		return s.slidingWindow(rule, now)
+	case FixedWindow:
+		return s.fixedWindow(rule, now)
+	default:
+		return s.tokenBucket(rule, now)
+	}
+}
+
+func (s *state) tokenBucket(rule Rule, now time.Time) Result {
+	limit := float64(rule.Limit)
+	// 每个令牌的补充间隔
+	interval := rule.Window / time.Duration(rule.Limit)
+	if s.last.IsZero() {
+		s.tokens = limit
+	} else if elapsed := now.Sub(s.last); elapsed > 0 {
+		s.tokens = math.Min(limit, s.tokens+float64(elapsed)/float64(interval))
+	}
+	s.last = now
+	result := Result{Limit: rule.Limit}
+	if s.tokens >= 1 {
+		s.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - s.tokens) * float64(interval))
+	}
+	result.Remaining = int(s.tokens)
+	result.ResetAfter = time.Duration((limit - s.tokens) * float64(interval))
+	return result
+}
+
+func (s *state) fixedWindow(rule Rule, now time.Time) Result {
+	s.roll(rule, now)
+	result := Result{Limit: rule.Limit, ResetAfter: s.last.Add(rule.Window).Sub(now)}
+	if s.tokens < float64(rule.Limit) {
+		s.tokens++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = result.ResetAfter
+	}
+	result.Remaining = rule.Limit - int(s.tokens)
+	return result
+}
+
+func (s *state) slidingWindow(rule Rule, now time.Time) Result {
+	s.roll(rule, now)
+	elapsed := now.Sub(s.last)
+	// 上一个窗口的请求按未滑出的比例计入
+	weight := 1 - float64(elapsed)/float64(rule.Window)
+	count := s.prev*weight + s.tokens
+	result := Result{Limit: rule.Limit, ResetAfter: s.last.Add(rule.Window).Sub(now)}
+	if count+1 <= float64(rule.Limit) {
+		s.tokens++
+		count++
+		result.Allowed = true
+	} else if s.prev > 0 && s.tokens < float64(rule.Limit) {
+		// 等上一个窗口再滑出一部分就有额度
+		need := count + 1 - float64(rule.Limit)
+		result.RetryAfter = time.Duration(need / s.prev * float64(rule.Window))
+	} else {
+		result.RetryAfter = result.ResetAfter
+	}
+	result.Remaining = int(math.Max(0, float64(rule.Limit)-count))
+	return result
+}
+
+// 进入新的窗口时 当前窗口成为上一个窗口
+func (s *state) roll(rule Rule, now time.Time) {
+	start := now.Truncate(rule.Window)
+	if s.last.Equal(start) {
+		return
+	}
+	if s.last.Add(rule.Window).Equal(start) {
+		s.prev = s.tokens
+	} else {
+		s.prev = 0
+	}
+	s.tokens = 0
+	s.last = start
+}
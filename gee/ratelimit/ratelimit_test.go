@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAlgorithms(t *testing.T) {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		rule Rule
+		// 请求时间相对start的偏移 和期望结果
+		steps []struct {
+			at      time.Duration
+			allowed bool
+		}
+	}{
+		{"token bucket", Rule{TokenBucket, 2, time.Second}, []struct {
+			at      time.Duration
+			allowed bool
+		}{{0, true}, {0, true}, {0, false}, {500 * time.Millisecond, true}, {500 * time.Millisecond, false}}},
+		{"fixed window", Rule{FixedWindow, 2, time.Second}, []struct {
+			at      time.Duration
+			allowed bool
+		}{{900 * time.Millisecond, true}, {900 * time.Millisecond, true}, {950 * time.Millisecond, false}, {time.Second, true}}},
+		// 上一个窗口2个请求 新窗口过了一半时还计入1个
+		{"sliding window", Rule{SlidingWindow, 2, time.Second}, []struct {
+			at      time.Duration
+			allowed bool
+		}{{900 * time.Millisecond, true}, {900 * time.Millisecond, true}, {1100 * time.Millisecond, false}, {1500 * time.Millisecond, true}, {1500 * time.Millisecond, false}}},
+	}
+	for _, tt := range tests {
+		store := NewMemoryStore(0)
+		for i, step := range tt.steps {
+			store.now = func() time.Time { return start.Add(step.at) }
+			result, err := store.Take(context.Background(), "k", tt.rule)
+			if err != nil || result.Allowed != step.allowed {
+				t.Errorf("%s step %d: got %+v %v", tt.name, i, result, err)
+			}
+			if !result.Allowed && result.RetryAfter <= 0 {
+				t.Errorf("%s step %d: RetryAfter not set", tt.name, i)
+			}
+		}
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	store := NewMemoryStore(2)
+	rule := Rule{FixedWindow, 1, time.Minute}
+	ctx := context.Background()
+	store.Take(ctx, "a", rule)
+	store.Take(ctx, "b", rule)
+	// a最近使用过 淘汰b
+	store.Take(ctx, "a", rule)
+	store.Take(ctx, "c", rule)
+	if store.Len() != 2 {
+		t.Fatalf("len %d", store.Len())
+	}
+	if r, _ := store.Take(ctx, "a", rule); r.Allowed {
+		t.Error("a should still be limited")
+	}
+	if r, _ := store.Take(ctx, "b", rule); !r.Allowed {
+		t.Error("b should have been evicted")
+	}
+}
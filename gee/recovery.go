@@ -3,7 +3,6 @@ package gee
 import (
 	"errors"
 	"fmt"
-	"net/http"
 	"runtime"
 	"strings"
 
@@ -24,20 +23,27 @@ func detailMsg(err any) string {
 	return sb.String()
 }
 
+// Recovery 捕获处理方法中的panic 交给错误处理流程 默认返回500
+// GeeError设置了处理函数时交给处理函数
 func Recovery(next HandlerFunc) HandlerFunc {
 	return func(ctx *Context) {
 		defer func() {
-			if err := recover(); err != nil {
-				err2 := err.(error)
-				if err2 != nil {
-					var gError *geeError.GeeError
-					if errors.As(err2, &gError) {
-						gError.ExecResult()
-						return
-					}
+			if rec := recover(); rec != nil {
+				err, ok := rec.(error)
+				if !ok {
+					err = fmt.Errorf("%v", rec)
 				}
-				ctx.Logger.Error(detailMsg(err))
-				ctx.Fail(http.StatusInternalServerError, "Internal Server Error")
+				var gError *geeError.GeeError
+				if errors.As(err, &gError) && gError.ErrFuc != nil {
+					gError.ExecResult()
+					return
+				}
+				ctx.Logger.Error(detailMsg(rec))
+				// 已经写入了响应 无法再修改状态码
+				if ctx.W.Written() {
+					return
+				}
+				ctx.Error(err)
 			}
 		}()
 
@@ -58,6 +58,13 @@ func (r *GeeEtcdRegister) RegisterService(serviceName string, host string, port
 	return err
 }
 
+func (r *GeeEtcdRegister) DeregisterService(serviceName string, host string, port int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := r.cli.Delete(ctx, serviceName)
+	return err
+}
+
 func (r *GeeEtcdRegister) GetValue(serviceName string) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
@@ -19,6 +19,8 @@ type Option struct {
 type GeeRegister interface {
 	CreateCli(option Option) error
 	RegisterService(serviceName string, host string, port int) error
+	// DeregisterService 注销服务 服务退出时调用
+	DeregisterService(serviceName string, host string, port int) error
 	GetValue(serviceName string) (string, error)
 	Close() error
 }
@@ -0,0 +1,85 @@
+package render
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileCache 缓存按文件路径解析的模板 供ctx.HTMLTemplate和ctx.HTMLTemplateGlob使用
+// 每次使用前检查文件的修改时间 文件增删或修改后重新解析
+// 缓存的模板只用于克隆 每次返回副本并绑定本次调用的funcMap 闭包不会在请求间共享
+type FileCache struct {
+	mu    sync.RWMutex
+	cache map[string]*cachedTemplate
+}
+
+// Files 解析files 执行时使用名为name的模板
+func (c *FileCache) Files(name string, funcMap template.FuncMap, files ...string) (*template.Template, error) {
+	return c.lookup("files", name, funcMap, files)
+}
+
+// Glob 解析pattern匹配的全部文件 执行时使用名为name的模板
+func (c *FileCache) Glob(name string, funcMap template.FuncMap, pattern string) (*template.Template, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		// 与template.ParseGlob的错误一致
+		_, err := template.New(name).ParseGlob(pattern)
+		return nil, err
+	}
+	return c.lookup("glob:"+pattern, name, funcMap, files)
+}
+
+func (c *FileCache) lookup(kind, name string, funcMap template.FuncMap, paths []string) (*template.Template, error) {
+	files := make([]templateFile, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, templateFile{name: path, modTime: info.ModTime()})
+	}
+	key := cacheKey(kind, name, paths, funcMap)
+
+	c.mu.RLock()
+	cached, ok := c.cache[key]
+	c.mu.RUnlock()
+	if ok && sameFiles(cached.files, files) {
+		return bindFuncs(cached.template, funcMap)
+	}
+	t, err := template.New(name).Funcs(funcMap).ParseFiles(paths...)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.cache == nil {
+		c.cache = make(map[string]*cachedTemplate)
+	}
+	c.cache[key] = &cachedTemplate{template: t, entry: name, files: files}
+	c.mu.Unlock()
+	return bindFuncs(t, funcMap)
+}
+
+// 模板函数的名字在解析时确定 实现在执行时替换
+func cacheKey(kind, name string, paths []string, funcMap template.FuncMap) string {
+	funcNames := make([]string, 0, len(funcMap))
+	for fn := range funcMap {
+		funcNames = append(funcNames, fn)
+	}
+	sort.Strings(funcNames)
+	return strings.Join([]string{kind, name, strings.Join(paths, "\x00"), strings.Join(funcNames, ",")}, "\x01")
+}
+
+func bindFuncs(t *template.Template, funcMap template.FuncMap) (*template.Template, error) {
+	clone, err := t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return clone.Funcs(funcMap), nil
+}
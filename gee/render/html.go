@@ -1,7 +1,9 @@
 package render
 
 import (
+	"bytes"
 	"html/template"
+	"io"
 	"net/http"
 
 	"github.com/gee-coder/gee/internal/bytesconv"
@@ -12,7 +14,15 @@ type HTML struct {
 	Template   *template.Template
 	IsTemplate bool
 	Data       any
+	// Replacer 不为nil时 把模板输出中的占位符替换为本次请求的值 如CSP nonce和CSRF token
+	Replacer StringReplacer
 }
+
+// StringReplacer 替换输出中的占位符 *strings.Replacer满足
+type StringReplacer interface {
+	WriteString(w io.Writer, s string) (n int, err error)
+}
+
 type HTMLRender struct {
 	Template *template.Template
 }
@@ -21,7 +31,14 @@ func (h *HTML) Render(w http.ResponseWriter, code int) error {
 	h.WriteContentType(w)
 	w.WriteHeader(code)
 	if h.IsTemplate {
-		err := h.Template.ExecuteTemplate(w, h.Name, h.Data)
+		if h.Replacer == nil {
+			return h.Template.ExecuteTemplate(w, h.Name, h.Data)
+		}
+		var buf bytes.Buffer
+		if err := h.Template.ExecuteTemplate(&buf, h.Name, h.Data); err != nil {
+			return err
+		}
+		_, err := h.Replacer.WriteString(w, buf.String())
 		return err
 	}
 	_, err := w.Write(bytesconv.StringToBytes(h.Data.(string)))
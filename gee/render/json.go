@@ -1,8 +1,15 @@
 package render
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/gee-coder/gee/internal/bytesconv"
 )
 
 type JSON struct {
@@ -23,3 +30,134 @@ func (j *JSON) Render(w http.ResponseWriter, code int) error {
 func (j *JSON) WriteContentType(w http.ResponseWriter) {
 	writeContentType(w, "application/json; charset=utf-8")
 }
+
+// 带缩进的JSON 便于调试时阅读
+type IndentedJSON struct {
+	Data any
+}
+
+func (j *IndentedJSON) Render(w http.ResponseWriter, code int) error {
+	j.WriteContentType(w)
+	w.WriteHeader(code)
+	jsonData, err := json.MarshalIndent(j.Data, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(jsonData)
+	return err
+}
+
+func (j *IndentedJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, "application/json; charset=utf-8")
+}
+
+// 数组前面加上前缀 防止JSON劫持 默认前缀为 while(1);
+type SecureJSON struct {
+	Prefix string
+	Data   any
+}
+
+func (j *SecureJSON) Render(w http.ResponseWriter, code int) error {
+	j.WriteContentType(w)
+	w.WriteHeader(code)
+	jsonData, err := json.Marshal(j.Data)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(jsonData, []byte("[")) && bytes.HasSuffix(jsonData, []byte("]")) {
+		prefix := j.Prefix
+		if prefix == "" {
+			prefix = "while(1);"
+		}
+		if _, err = w.Write(bytesconv.StringToBytes(prefix)); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(jsonData)
+	return err
+}
+
+func (j *SecureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, "application/json; charset=utf-8")
+}
+
+// 非ASCII字符转义成 \uXXXX
+type AsciiJSON struct {
+	Data any
+}
+
+func (j *AsciiJSON) Render(w http.ResponseWriter, code int) error {
+	j.WriteContentType(w)
+	w.WriteHeader(code)
+	jsonData, err := json.Marshal(j.Data)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for len(jsonData) > 0 {
+		r, size := utf8.DecodeRune(jsonData)
+		if r < utf8.RuneSelf {
+			buf.WriteByte(jsonData[0])
+		} else if r > 0xFFFF {
+			// 超出基本平面的字符用代理对表示
+			r -= 0x10000
+			fmt.Fprintf(&buf, `\u%04x\u%04x`, 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+		} else {
+			fmt.Fprintf(&buf, `\u%04x`, r)
+		}
+		jsonData = jsonData[size:]
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (j *AsciiJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, "application/json")
+}
+
+// JSONP Callback为空时等同于JSON
+type JSONP struct {
+	Callback string
+	Data     any
+}
+
+// 回调名只能是标识符 可以带点 如 jQuery123.cb
+var jsonpCallbackPattern = regexp.MustCompile(`^[A-Za-z_$][\w$]*(\.[A-Za-z_$][\w$]*)*$`)
+
+// ErrInvalidCallback 回调名不是合法的标识符
+var ErrInvalidCallback = errors.New("render: invalid JSONP callback")
+
+// ValidJSONPCallback 回调名是否为合法的标识符 不合法的回调名可以注入脚本
+func ValidJSONPCallback(callback string) bool {
+	return len(callback) <= 128 && jsonpCallbackPattern.MatchString(callback)
+}
+
+func (j *JSONP) Render(w http.ResponseWriter, code int) error {
+	if j.Callback != "" && !ValidJSONPCallback(j.Callback) {
+		return ErrInvalidCallback
+	}
+	jsonData, err := json.Marshal(j.Data)
+	if err != nil {
+		return err
+	}
+	j.WriteContentType(w)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	if j.Callback == "" {
+		_, err = w.Write(jsonData)
+		return err
+	}
+	// 开头的注释避免响应被当作Flash等其他格式解析
+	if _, err = w.Write(bytesconv.StringToBytes("/**/" + j.Callback + "(")); err != nil {
+		return err
+	}
+	if _, err = w.Write(jsonData); err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(");"))
+	return err
+}
+
+func (j *JSONP) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, "application/javascript; charset=utf-8")
+}
@@ -0,0 +1,26 @@
+package render
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemJSON RFC 7807 问题详情
+type ProblemJSON struct {
+	Data any
+}
+
+func (p *ProblemJSON) Render(w http.ResponseWriter, code int) error {
+	p.WriteContentType(w)
+	w.WriteHeader(code)
+	jsonData, err := json.Marshal(p.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(jsonData)
+	return err
+}
+
+func (p *ProblemJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, "application/problem+json")
+}
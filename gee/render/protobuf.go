@@ -0,0 +1,32 @@
+package render
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Data必须实现proto.Message
+type ProtoBuf struct {
+	Data any
+}
+
+func (p *ProtoBuf) Render(w http.ResponseWriter, code int) error {
+	msg, ok := p.Data.(proto.Message)
+	if !ok {
+		return errors.New("data is not proto.Message")
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	p.WriteContentType(w)
+	w.WriteHeader(code)
+	_, err = w.Write(data)
+	return err
+}
+
+func (p *ProtoBuf) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, "application/x-protobuf")
+}
@@ -0,0 +1,84 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gee-coder/gee/internal/bytesconv"
+)
+
+// SSE Server-Sent Events中的一个事件
+// 可以在同一个响应中多次渲染 状态码在第一次写入时发送
+type SSE struct {
+	Event string
+	Id    string
+	// 断线重连的间隔 毫秒 0表示不发送
+	Retry uint
+	// 字符串和[]byte原样发送 其他类型编码成JSON
+	Data any
+}
+
+// 事件名和id中不能出现换行 否则会被客户端当成新的字段
+var fieldReplacer = strings.NewReplacer("\n", "", "\r", "")
+
+// 数据中的换行拆成多个data字段
+var dataReplacer = strings.NewReplacer("\r\n", "\ndata:", "\n", "\ndata:", "\r", "\ndata:")
+
+func (s *SSE) Render(w http.ResponseWriter, code int) error {
+	s.WriteContentType(w)
+	return s.encode(w)
+}
+
+func (s *SSE) WriteContentType(w http.ResponseWriter) {
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	if header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", "no-cache")
+	}
+}
+
+func (s *SSE) encode(w io.Writer) error {
+	var sb strings.Builder
+	if s.Id != "" {
+		sb.WriteString("id:")
+		sb.WriteString(fieldReplacer.Replace(s.Id))
+		sb.WriteString("\n")
+	}
+	if s.Event != "" {
+		sb.WriteString("event:")
+		sb.WriteString(fieldReplacer.Replace(s.Event))
+		sb.WriteString("\n")
+	}
+	if s.Retry > 0 {
+		fmt.Fprintf(&sb, "retry:%d\n", s.Retry)
+	}
+	data, err := sseData(s.Data)
+	if err != nil {
+		return err
+	}
+	sb.WriteString("data:")
+	sb.WriteString(dataReplacer.Replace(data))
+	sb.WriteString("\n\n")
+	_, err = w.Write(bytesconv.StringToBytes(sb.String()))
+	return err
+}
+
+func sseData(data any) (string, error) {
+	switch d := data.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return d, nil
+	case []byte:
+		return string(d), nil
+	default:
+		jsonData, err := json.Marshal(d)
+		if err != nil {
+			return "", err
+		}
+		return string(jsonData), nil
+	}
+}
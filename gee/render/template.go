@@ -0,0 +1,218 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+// TemplateManager 管理页面模板 支持布局、局部模板和多个模板目录
+// 生产模式下每种布局和页面的组合只解析一次 开发模式下文件修改后重新解析
+//
+//	layouts/base.html   {{block "content" .}}{{end}}
+//	partials/nav.html   {{define "nav"}}...{{end}}
+//	user/login.html     {{define "content"}}...{{template "nav" .}}...{{end}}
+//	ctx.HTMLLayout(200, "base.html", "user/login.html", data)
+type TemplateManager struct {
+	// 模板目录 按顺序查找 同名文件使用第一个目录中的
+	Roots []fs.FS
+	// 布局文件所在的目录 默认layouts
+	LayoutDir string
+	// 局部模板的匹配规则 每个页面都会加载 默认partials/*.html
+	Partials []string
+	FuncMap  template.FuncMap
+	// 开发模式 每次渲染前检查文件是否修改
+	Debug bool
+
+	mu    sync.RWMutex
+	cache map[string]*cachedTemplate
+}
+
+type cachedTemplate struct {
+	template *template.Template
+	// 执行的模板 有布局时为布局文件
+	entry string
+	files []templateFile
+}
+
+// 记录解析时的文件 开发模式下用来判断是否需要重新解析
+type templateFile struct {
+	name    string
+	root    int
+	modTime time.Time
+}
+
+// NewTemplateManager roots为模板目录 如os.DirFS("tpl")或embed.FS
+func NewTemplateManager(roots ...fs.FS) *TemplateManager {
+	return &TemplateManager{Roots: roots}
+}
+
+func (m *TemplateManager) layoutDir() string {
+	if m.LayoutDir == "" {
+		return "layouts"
+	}
+	return m.LayoutDir
+}
+
+func (m *TemplateManager) partials() []string {
+	if m.Partials == nil {
+		return []string{"partials/*.html"}
+	}
+	return m.Partials
+}
+
+// Lookup 返回布局和页面组合后的模板及要执行的模板名 layout为空时直接执行页面
+func (m *TemplateManager) Lookup(layout, name string) (*template.Template, string, error) {
+	key := layout + "\x00" + name
+	m.mu.RLock()
+	cached, ok := m.cache[key]
+	m.mu.RUnlock()
+	if ok && !m.Debug {
+		return cached.template, cached.entry, nil
+	}
+	files, entry, err := m.files(layout, name)
+	if err != nil {
+		return nil, "", err
+	}
+	if ok && sameFiles(cached.files, files) {
+		return cached.template, cached.entry, nil
+	}
+	cached, err = m.parse(files, entry)
+	if err != nil {
+		return nil, "", err
+	}
+	m.mu.Lock()
+	if m.cache == nil {
+		m.cache = make(map[string]*cachedTemplate)
+	}
+	m.cache[key] = cached
+	m.mu.Unlock()
+	return cached.template, cached.entry, nil
+}
+
+// 按解析顺序排列的文件 局部模板、布局、页面 后解析的define覆盖先解析的block
+// entry为要执行的模板 有布局时为布局文件 否则为页面
+func (m *TemplateManager) files(layout, name string) (files []templateFile, entry string, err error) {
+	seen := make(map[string]bool)
+	for _, pattern := range m.partials() {
+		for i, root := range m.Roots {
+			matches, err := fs.Glob(root, pattern)
+			if err != nil {
+				return nil, "", err
+			}
+			for _, match := range matches {
+				if seen[match] {
+					continue
+				}
+				seen[match] = true
+				file, err := m.stat(match, i)
+				if err != nil {
+					return nil, "", err
+				}
+				files = append(files, file)
+			}
+		}
+	}
+	entry = name
+	if layout != "" {
+		file, err := m.find(path.Join(m.layoutDir(), layout))
+		if err != nil {
+			return nil, "", err
+		}
+		files = append(files, file)
+		entry = file.name
+	}
+	file, err := m.find(name)
+	if err != nil {
+		return nil, "", err
+	}
+	return append(files, file), entry, nil
+}
+
+func (m *TemplateManager) find(name string) (templateFile, error) {
+	for i := range m.Roots {
+		if file, err := m.stat(name, i); err == nil {
+			return file, nil
+		}
+	}
+	return templateFile{}, fmt.Errorf("render: template %s not found", name)
+}
+
+func (m *TemplateManager) stat(name string, root int) (templateFile, error) {
+	info, err := fs.Stat(m.Roots[root], name)
+	if err != nil {
+		return templateFile{}, err
+	}
+	if info.IsDir() {
+		return templateFile{}, errors.New("render: template " + name + " is a directory")
+	}
+	return templateFile{name: name, root: root, modTime: info.ModTime()}, nil
+}
+
+func (m *TemplateManager) parse(files []templateFile, entry string) (*cachedTemplate, error) {
+	t := template.New("").Funcs(m.FuncMap)
+	for _, file := range files {
+		content, err := fs.ReadFile(m.Roots[file.root], file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := t.New(file.name).Parse(string(content)); err != nil {
+			return nil, err
+		}
+	}
+	return &cachedTemplate{template: t, entry: entry, files: files}, nil
+}
+
+func sameFiles(a, b []templateFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// HTMLLayout 使用TemplateManager渲染 先写入缓冲区 执行出错时不会输出半个页面
+type HTMLLayout struct {
+	Manager *TemplateManager
+	Layout  string
+	Name    string
+	Data    any
+	// Replacer 不为nil时 把模板输出中的占位符替换为本次请求的值
+	Replacer StringReplacer
+}
+
+func (h *HTMLLayout) Render(w http.ResponseWriter, code int) error {
+	if h.Manager == nil {
+		return errors.New("render: template manager is not set")
+	}
+	t, entry, err := h.Manager.Lookup(h.Layout, h.Name)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, entry, h.Data); err != nil {
+		return err
+	}
+	h.WriteContentType(w)
+	w.WriteHeader(code)
+	if h.Replacer != nil {
+		_, err = h.Replacer.WriteString(w, buf.String())
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func (h *HTMLLayout) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, "text/html; charset=utf-8")
+}
@@ -0,0 +1,102 @@
+package render
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestTemplateManager(t *testing.T) {
+	shared := fstest.MapFS{
+		"layouts/base.html": {Data: []byte(`<title>{{block "title" .}}gee{{end}}</title>{{template "nav" .}}{{block "content" .}}{{end}}`)},
+		"partials/nav.html": {Data: []byte(`{{define "nav"}}<nav>{{.}}</nav>{{end}}`)},
+		"index.html":        {Data: []byte(`{{define "content"}}shared index{{end}}`)},
+	}
+	dir := t.TempDir()
+	page := filepath.Join(dir, "index.html")
+	os.WriteFile(page, []byte(`{{define "title"}}home{{end}}{{define "content"}}<p>{{.}}</p>{{end}}`), 0644)
+	m := NewTemplateManager(os.DirFS(dir), shared)
+
+	render := func(layout, name string) string {
+		w := httptest.NewRecorder()
+		if err := (&HTMLLayout{Manager: m, Layout: layout, Name: name, Data: "gee"}).Render(w, 200); err != nil {
+			t.Fatal(err)
+		}
+		return w.Body.String()
+	}
+	// 同名页面使用第一个目录中的
+	if got := render("base.html", "index.html"); got != "<title>home</title><nav>gee</nav><p>gee</p>" {
+		t.Errorf("layout: got %q", got)
+	}
+	if got := render("", "partials/nav.html"); got != "" {
+		t.Errorf("page without layout: got %q", got)
+	}
+	if _, _, err := m.Lookup("none.html", "index.html"); err == nil {
+		t.Error("expected error for missing layout")
+	}
+
+	// 生产模式使用缓存 开发模式重新解析修改过的文件
+	os.WriteFile(page, []byte(`{{define "content"}}changed{{end}}`), 0644)
+	os.Chtimes(page, time.Now(), time.Now().Add(time.Second))
+	if got := render("base.html", "index.html"); !strings.Contains(got, "<p>gee</p>") {
+		t.Errorf("cached: got %q", got)
+	}
+	m.Debug = true
+	if got := render("base.html", "index.html"); got != "<title>gee</title><nav>gee</nav>changed" {
+		t.Errorf("reload: got %q", got)
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "page.html")
+	os.WriteFile(page, []byte(`v1`), 0644)
+	var c FileCache
+	parsed := func() *template.Template {
+		return c.cache[cacheKey("files", "page.html", []string{page}, nil)].template
+	}
+	if _, err := c.Files("page.html", nil, page); err != nil {
+		t.Fatal(err)
+	}
+	first := parsed()
+	c.Glob("page.html", nil, filepath.Join(dir, "*.html"))
+	if len(c.cache) != 2 {
+		t.Error("glob should be cached separately")
+	}
+	if again, _ := c.Files("page.html", nil, page); again == first || parsed() != first {
+		t.Error("template parsed again without changes or cached template returned")
+	}
+	// 修改文件后重新解析
+	os.WriteFile(page, []byte(`v2`), 0644)
+	os.Chtimes(page, time.Now(), time.Now().Add(time.Minute))
+	reloaded, err := c.Files("page.html", nil, page)
+	var buf strings.Builder
+	if err != nil || parsed() == first || reloaded.Execute(&buf, nil) != nil || buf.String() != "v2" {
+		t.Errorf("reload: %v %q", err, buf.String())
+	}
+	if _, err := c.Glob("page.html", nil, filepath.Join(dir, "*.none")); err == nil {
+		t.Error("empty glob should fail")
+	}
+}
+
+func TestFileCacheFuncsPerCall(t *testing.T) {
+	page := filepath.Join(t.TempDir(), "user.html")
+	os.WriteFile(page, []byte(`{{user}}`), 0644)
+	var c FileCache
+	for _, name := range []string{"alice", "bob", "carol"} {
+		name := name
+		tmpl, err := c.Files("user.html", template.FuncMap{"user": func() string { return name }}, page)
+		var buf strings.Builder
+		if err != nil || tmpl.Execute(&buf, nil) != nil || buf.String() != name {
+			t.Errorf("want %q, got %q %v", name, buf.String(), err)
+		}
+	}
+	if len(c.cache) != 1 {
+		t.Errorf("want 1 cached template, got %d", len(c.cache))
+	}
+}
@@ -0,0 +1,76 @@
+package gee
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gee-coder/gee/internal/requestid"
+	geeLog "github.com/gee-coder/gee/log"
+)
+
+// RequestIDConfig 请求ID中间件的配置
+type RequestIDConfig struct {
+	// 读取和返回请求ID的请求头 默认X-Request-ID
+	Header string
+	// 生成请求ID 默认为32位十六进制随机数
+	Generator func() string
+}
+
+// RequestID 沿用上游传来的X-Request-ID 没有时生成一个
+// 请求ID写入响应头、ctx.Logger的fields和请求的context rpc.GeeHttpClient会转发给下游服务
+func RequestID(next HandlerFunc) HandlerFunc {
+	return RequestIDWithConfig(RequestIDConfig{}, next)
+}
+
+func RequestIDWithConfig(conf RequestIDConfig, next HandlerFunc) HandlerFunc {
+	header := conf.Header
+	if header == "" {
+		header = requestid.Header
+	}
+	generator := conf.Generator
+	if generator == nil {
+		generator = newRequestID
+	}
+	return func(ctx *Context) {
+		id := ctx.R.Header.Get(header)
+		if !validRequestID(id) {
+			id = generator()
+		}
+		ctx.requestID = id
+		ctx.W.Header().Set(header, id)
+		ctx.R = ctx.R.WithContext(requestid.NewContext(ctx.R.Context(), id))
+		if ctx.Logger != nil {
+			fields := make(geeLog.Fields, len(ctx.Logger.LoggerFields)+1)
+			for k, v := range ctx.Logger.LoggerFields {
+				fields[k] = v
+			}
+			fields["request_id"] = id
+			ctx.Logger = ctx.Logger.WithFields(fields)
+		}
+		next(ctx)
+	}
+}
+
+// RequestID 当前请求的ID 没有使用RequestID中间件时为空
+func (c *Context) RequestID() string {
+	return c.requestID
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 上游传来的ID只接受可见字符 避免写入日志和响应头的内容被伪造
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package gee
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"net/http"
+)
+
+const noWritten = -1
+
+// ResponseWriter 记录响应状态的http.ResponseWriter
+// 中间件在处理方法执行后可以拿到真实的状态码和响应大小
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.Pusher
+	// Status 响应状态码 未写入时为200
+	Status() int
+	// Size 已写入的响应体字节数 未写入时为-1
+	Size() int
+	// Written 是否已写入响应头或响应体
+	Written() bool
+	// Unwrap 返回被包裹的http.ResponseWriter 供http.ResponseController使用
+	Unwrap() http.ResponseWriter
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *responseWriter) reset(writer http.ResponseWriter) {
+	w.ResponseWriter = writer
+	w.status = http.StatusOK
+	w.size = noWritten
+}
+
+// WriteHeader 重复调用时只保留第一次的状态码
+func (w *responseWriter) WriteHeader(code int) {
+	if w.Written() {
+		log.Printf("[WARNING] headers were already written, wanted to override status code %d with %d", w.status, code)
+		return
+	}
+	w.status = code
+	w.size = 0
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.Written() {
+		w.size = 0
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) Flush() {
+	if !w.Written() {
+		w.size = 0
+	}
+	if err := http.NewResponseController(w.ResponseWriter).Flush(); err != nil {
+		log.Println(err)
+	}
+}
+
+// Hijack 接管连接后不能再通过ResponseWriter写入
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if !w.Written() {
+		w.size = 0
+	}
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Push HTTP/2服务端推送 底层不支持时返回http.ErrNotSupported
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	writer := w.ResponseWriter
+	for {
+		switch t := writer.(type) {
+		case http.Pusher:
+			return t.Push(target, opts)
+		case interface{ Unwrap() http.ResponseWriter }:
+			writer = t.Unwrap()
+		default:
+			return http.ErrNotSupported
+		}
+	}
+}
+
+// HEAD请求 只保留响应头和状态码
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+var _ ResponseWriter = (*responseWriter)(nil)
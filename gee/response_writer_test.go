@@ -0,0 +1,69 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &responseWriter{}
+	w.reset(rec)
+	if w.Written() || w.Status() != http.StatusOK || w.Size() != noWritten {
+		t.Fatalf("unexpected initial state %d %d %v", w.Status(), w.Size(), w.Written())
+	}
+	w.WriteHeader(http.StatusNotFound)
+	// 重复写入状态码被忽略
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("hello"))
+	w.Flush()
+	if w.Status() != http.StatusNotFound || w.Size() != 5 || !w.Written() {
+		t.Errorf("got status %d size %d, want 404 5", w.Status(), w.Size())
+	}
+	if rec.Code != http.StatusNotFound || !rec.Flushed {
+		t.Errorf("recorder got %d flushed %v", rec.Code, rec.Flushed)
+	}
+}
+
+func TestResponseWriterStatusSeenByMiddleware(t *testing.T) {
+	engine := New()
+	var status, size int
+	engine.AddMiddlewareFunc(func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			next(ctx)
+			status, size = ctx.W.Status(), ctx.W.Size()
+		}
+	})
+	g := engine.Group("user")
+	g.Get("/direct", func(ctx *Context) {
+		ctx.W.WriteHeader(http.StatusAccepted)
+		ctx.W.Write([]byte("ok"))
+	})
+	g.Get("/hijack", func(ctx *Context) {
+		conn, buf, err := ctx.W.Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 204 No Content\r\n\r\n")
+		buf.Flush()
+	})
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/direct", nil))
+	if status != http.StatusAccepted || size != 2 {
+		t.Errorf("middleware got status %d size %d, want 202 2", status, size)
+	}
+
+	server := httptest.NewServer(engine)
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/user/hijack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("hijacked response got %d, want 204", resp.StatusCode)
+	}
+}
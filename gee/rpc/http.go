@@ -3,6 +3,7 @@ package rpc
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,6 +14,8 @@ import (
 	"reflect"
 	"strings"
 	"time"
+
+	"github.com/gee-coder/gee/internal/requestid"
 )
 
 type GeeHttpClient struct {
@@ -73,7 +76,7 @@ func (c *GeeHttpClientSession) Get(url string, args map[string]any) ([]byte, err
 		url = url + "?" + c.toValues(args)
 	}
 	log.Println(url)
-	request, err := http.NewRequest("GET", url, nil)
+	request, err := http.NewRequestWithContext(c.context(), "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -82,7 +85,7 @@ func (c *GeeHttpClientSession) Get(url string, args map[string]any) ([]byte, err
 }
 
 func (c *GeeHttpClientSession) PostForm(url string, args map[string]any) ([]byte, error) {
-	request, err := http.NewRequest("POST", url, strings.NewReader(c.toValues(args)))
+	request, err := http.NewRequestWithContext(c.context(), "POST", url, strings.NewReader(c.toValues(args)))
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +94,7 @@ func (c *GeeHttpClientSession) PostForm(url string, args map[string]any) ([]byte
 
 func (c *GeeHttpClientSession) PostJson(url string, args map[string]any) ([]byte, error) {
 	marshal, _ := json.Marshal(args)
-	request, err := http.NewRequest("POST", url, bytes.NewReader(marshal))
+	request, err := http.NewRequestWithContext(c.context(), "POST", url, bytes.NewReader(marshal))
 	if err != nil {
 		return nil, err
 	}
@@ -99,7 +102,13 @@ func (c *GeeHttpClientSession) PostJson(url string, args map[string]any) ([]byte
 }
 
 func (c *GeeHttpClientSession) responseHandle(request *http.Request) ([]byte, error) {
-	c.ReqHandler(request)
+	// 转发上游的请求ID 便于串联各服务的日志
+	if id := requestid.FromContext(request.Context()); id != "" && request.Header.Get(requestid.Header) == "" {
+		request.Header.Set(requestid.Header, id)
+	}
+	if c.ReqHandler != nil {
+		c.ReqHandler(request)
+	}
 	response, err := c.client.Do(request)
 	if err != nil {
 		return nil, err
@@ -135,7 +144,7 @@ func (c *GeeHttpClientSession) responseHandle(request *http.Request) ([]byte, er
 }
 
 func (c *GeeHttpClient) NewSession() *GeeHttpClientSession {
-	return &GeeHttpClientSession{c, nil}
+	return &GeeHttpClientSession{GeeHttpClient: c}
 }
 
 func (c *GeeHttpClient) toValues(args map[string]any) string {
@@ -172,6 +181,8 @@ type GeeService interface {
 type GeeHttpClientSession struct {
 	*GeeHttpClient
 	ReqHandler func(req *http.Request)
+	// 发出请求使用的context 携带请求ID时会转发给下游
+	ctx context.Context
 }
 
 func (c *GeeHttpClient) RegisterHttpService(name string, service GeeService) {
@@ -179,10 +190,24 @@ func (c *GeeHttpClient) RegisterHttpService(name string, service GeeService) {
 }
 
 func (c *GeeHttpClient) Session() *GeeHttpClientSession {
-	return &GeeHttpClientSession{
-		c, nil,
+	return &GeeHttpClientSession{GeeHttpClient: c}
+}
+
+// WithContext 返回使用ctx发出请求的会话 传入ctx.R.Context()即可把请求ID转发给下游服务
+func (c *GeeHttpClientSession) WithContext(ctx context.Context) *GeeHttpClientSession {
+	s := *c
+	s.ctx = ctx
+	return &s
+}
+
+func (c *GeeHttpClientSession) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
 	}
+	return c.ctx
 }
+
+// Do 返回注册的服务的副本 副本中名为method的字段被设置为发起请求的函数
 func (c *GeeHttpClientSession) Do(service string, method string) GeeService {
 	geeService, ok := c.serviceMap[service]
 	if !ok {
@@ -195,7 +220,9 @@ func (c *GeeHttpClientSession) Do(service string, method string) GeeService {
 		panic(errors.New("service not pointer"))
 	}
 	tVar := t.Elem()
-	vVar := v.Elem()
+	// 每次调用使用注册的服务的副本 请求的context只保存在副本中 并发调用互不影响
+	vVar := reflect.New(tVar).Elem()
+	vVar.Set(v.Elem())
 	fieldIndex := -1
 	for i := 0; i < tVar.NumField(); i++ {
 		name := tVar.Field(i).Name
@@ -233,7 +260,7 @@ func (c *GeeHttpClientSession) Do(service string, method string) GeeService {
 	}
 	fValue := reflect.ValueOf(f)
 	vVar.Field(fieldIndex).Set(fValue)
-	return geeService
+	return vVar.Addr().Interface().(GeeService)
 }
 
 func (c HttpConfig) Prefix() string {
@@ -0,0 +1,107 @@
+package gee
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SecureOptions 安全响应头的配置 字段为空时使用括号中的默认值 设为"-"时不发送
+type SecureOptions struct {
+	// Strict-Transport-Security的max-age 为0时不发送 只在HTTPS请求中发送
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// Content-Security-Policy 其中的{nonce}替换为每个请求生成的nonce
+	// 如 "script-src 'self' 'nonce-{nonce}'" 模板中使用 <script nonce="{{ cspNonce }}">
+	ContentSecurityPolicy string
+	// 只报告不拦截 使用Content-Security-Policy-Report-Only
+	CSPReportOnly bool
+	// X-Frame-Options (DENY)
+	FrameOptions string
+	// Referrer-Policy (strict-origin-when-cross-origin)
+	ReferrerPolicy string
+	// Cross-Origin-Opener-Policy (same-origin)
+	CrossOriginOpenerPolicy string
+	// Permissions-Policy 如 "camera=(), microphone=()"
+	PermissionsPolicy string
+}
+
+// Secure 设置安全相关的响应头 X-Content-Type-Options总是为nosniff
+func Secure(opts SecureOptions) MiddlewareFunc {
+	headers := [][2]string{{"X-Content-Type-Options", "nosniff"}}
+	add := func(name, value, def string) {
+		if value == "" {
+			value = def
+		}
+		if value != "" && value != "-" {
+			headers = append(headers, [2]string{name, value})
+		}
+	}
+	add("X-Frame-Options", opts.FrameOptions, "DENY")
+	add("Referrer-Policy", opts.ReferrerPolicy, "strict-origin-when-cross-origin")
+	add("Cross-Origin-Opener-Policy", opts.CrossOriginOpenerPolicy, "same-origin")
+	add("Permissions-Policy", opts.PermissionsPolicy, "")
+	hsts := ""
+	if opts.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(opts.HSTSMaxAge/time.Second), 10)
+		if opts.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if opts.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	cspHeader := "Content-Security-Policy"
+	if opts.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	useNonce := strings.Contains(opts.ContentSecurityPolicy, "{nonce}")
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			header := ctx.W.Header()
+			for _, h := range headers {
+				header.Set(h[0], h[1])
+			}
+			if hsts != "" && ctx.IsHTTPS() {
+				header.Set("Strict-Transport-Security", hsts)
+			}
+			if opts.ContentSecurityPolicy != "" {
+				csp := opts.ContentSecurityPolicy
+				if useNonce {
+					ctx.cspNonce = newNonce()
+					csp = strings.ReplaceAll(csp, "{nonce}", ctx.cspNonce)
+				}
+				header.Set(cspHeader, csp)
+			}
+			next(ctx)
+		}
+	}
+}
+
+// CSPNonce Secure中间件为本次请求生成的nonce 没有时为空
+func (c *Context) CSPNonce() string {
+	return c.cspNonce
+}
+
+// IsHTTPS 请求是否通过HTTPS发出 来自可信代理时读取X-Forwarded-Proto
+func (c *Context) IsHTTPS() bool {
+	if c.R.TLS != nil {
+		return true
+	}
+	ip := net.ParseIP(c.RemoteIP())
+	if ip == nil || !c.engine.isTrustedProxy(ip) {
+		return false
+	}
+	return strings.EqualFold(c.R.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// 128位随机数 base64url编码 可以直接放入HTML属性和响应头
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
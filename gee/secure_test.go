@@ -0,0 +1,107 @@
+package gee
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gee-coder/gee/binding"
+	"github.com/gee-coder/gee/render"
+)
+
+func TestSecureAndCSRF(t *testing.T) {
+	engine := New()
+	engine.AddMiddlewareFunc(Secure(SecureOptions{
+		ContentSecurityPolicy: "script-src 'self' 'nonce-{nonce}'",
+	}), CSRF(CSRFOptions{}))
+	engine.SetTemplateManager(render.NewTemplateManager(fstest.MapFS{
+		"login.html": {Data: []byte(`<form>{{ csrfField }}</form><script nonce="{{ cspNonce }}">var t = "{{ csrfToken }}";</script>`)},
+	}))
+	g := engine.Group("user")
+	g.Get("/login", func(ctx *Context) {
+		ctx.HTMLLayout(http.StatusOK, "", "login.html", nil)
+	})
+	g.Post("/login", func(ctx *Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/login", nil))
+	h := w.Header()
+	if h.Get("X-Frame-Options") != "DENY" || h.Get("X-Content-Type-Options") != "nosniff" || h.Get("Strict-Transport-Security") != "" {
+		t.Errorf("headers: %v", h)
+	}
+	nonce := strings.TrimSuffix(strings.TrimPrefix(h.Get("Content-Security-Policy"), "script-src 'self' 'nonce-"), "'")
+	m := regexp.MustCompile(`^<form><input type="hidden" name="csrf_token" value="([\w-]+)"></form><script nonce="([\w-]+)">var t = "([\w-]+)";</script>$`).
+		FindStringSubmatch(w.Body.String())
+	if m == nil || m[2] != nonce || m[1] != m[3] {
+		t.Fatalf("body: %s nonce %s", w.Body.String(), nonce)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("cookies: %v", cookies)
+	}
+
+	post := func(token string, withCookie bool) int {
+		r := httptest.NewRequest(http.MethodPost, "/user/login", strings.NewReader(url.Values{"csrf_token": {token}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if withCookie {
+			r.AddCookie(cookies[0])
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := post(m[1], true); code != http.StatusOK {
+		t.Errorf("valid token: %d", code)
+	}
+	if code := post("", true); code != http.StatusForbidden {
+		t.Errorf("missing token: %d", code)
+	}
+	if code := post(m[1], false); code != http.StatusForbidden {
+		t.Errorf("missing cookie: %d", code)
+	}
+}
+
+func TestNegotiateHTMLReplacesMarkers(t *testing.T) {
+	engine := New()
+	engine.AddMiddlewareFunc(Secure(SecureOptions{ContentSecurityPolicy: "script-src 'nonce-{nonce}'"}))
+	engine.SetHtmlTemplate(template.Must(template.New("page").Funcs(TemplateFuncs()).Parse(`<script nonce="{{ cspNonce }}"></script>{{ csrfField }}`)))
+	engine.Group("page").Get("", func(ctx *Context) {
+		ctx.Negotiate(http.StatusOK, Negotiate{Offered: []string{binding.MIMEHTML}, HTMLName: "page"})
+	})
+	r := httptest.NewRequest(http.MethodGet, "/page", nil)
+	r.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	want := `<script nonce="` + strings.TrimSuffix(strings.TrimPrefix(w.Header().Get("Content-Security-Policy"), "script-src 'nonce-"), "'") + `"></script>`
+	if w.Body.String() != want {
+		t.Errorf("got %q, want %q", w.Body.String(), want)
+	}
+}
+
+func TestTemplateReplacer(t *testing.T) {
+	c := &Context{}
+	if c.templateReplacer() != nil {
+		t.Error("replacer without Secure or CSRF")
+	}
+	c.cspNonce = "n"
+	replacer := c.templateReplacer()
+	var sb strings.Builder
+	replacer.WriteString(&sb, "<p>plain</p>")
+	if c.replacer != nil || sb.String() != "<p>plain</p>" {
+		t.Errorf("replacer built without markers: %q", sb.String())
+	}
+	sb.Reset()
+	replacer.WriteString(&sb, cspNonceMarker+csrfFieldMarker)
+	built := c.replacer
+	replacer.WriteString(&sb, cspNonceMarker)
+	if built == nil || c.replacer != built || sb.String() != "nn" {
+		t.Errorf("got %q", sb.String())
+	}
+}
@@ -0,0 +1,302 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gee-coder/gee/register"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// 优雅关闭时默认等待请求处理完成的时间
+const defaultShutdownTimeout = 10 * time.Second
+
+// 服务配置 零值表示不限制 与http.Server同名字段含义相同
+type ServerOptions struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// 不使用TLS时支持HTTP/2明文传输(h2c)
+	H2C bool
+	// 指定监听器 设置后Run不再监听端口 如systemd传入的文件描述符
+	Listener net.Listener
+}
+
+// 从配置文件的[server]加载服务配置
+// 时间可以写成 "5s" 这样的字符串 或者以秒为单位的整数
+//
+//	[server]
+//	read_timeout = "5s"
+//	write_timeout = 10
+//	idle_timeout = "1m"
+//	max_header_bytes = 1048576
+//	h2c = true
+//	shutdown_timeout = "15s"
+func (e *Engine) loadServerConf(conf map[string]any) error {
+	durations := map[string]*time.Duration{
+		"read_timeout":        &e.ServerOptions.ReadTimeout,
+		"read_header_timeout": &e.ServerOptions.ReadHeaderTimeout,
+		"write_timeout":       &e.ServerOptions.WriteTimeout,
+		"idle_timeout":        &e.ServerOptions.IdleTimeout,
+		"shutdown_timeout":    &e.ShutdownTimeout,
+	}
+	for key, value := range conf {
+		switch key {
+		case "max_header_bytes":
+			n, ok := value.(int64)
+			if !ok {
+				return fmt.Errorf("config server.%s must be an integer", key)
+			}
+			e.ServerOptions.MaxHeaderBytes = int(n)
+		case "h2c":
+			b, ok := value.(bool)
+			if !ok {
+				return fmt.Errorf("config server.%s must be a boolean", key)
+			}
+			e.ServerOptions.H2C = b
+		default:
+			d, ok := durations[key]
+			if !ok {
+				continue
+			}
+			switch v := value.(type) {
+			case string:
+				parsed, err := time.ParseDuration(v)
+				if err != nil {
+					return fmt.Errorf("config server.%s: %w", key, err)
+				}
+				*d = parsed
+			case int64:
+				*d = time.Duration(v) * time.Second
+			default:
+				return fmt.Errorf("config server.%s must be a duration string or seconds", key)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Engine) newServer(h2cEnabled bool) *http.Server {
+	opts := e.ServerOptions
+	var handler http.Handler = e
+	if h2cEnabled && opts.H2C {
+		handler = h2c.NewHandler(e, &http2.Server{IdleTimeout: opts.IdleTimeout})
+	}
+	return &http.Server{
+		Handler:           handler,
+		ReadTimeout:       opts.ReadTimeout,
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+		MaxHeaderBytes:    opts.MaxHeaderBytes,
+	}
+}
+
+// OnStart 添加启动钩子 端口监听成功后、开始处理请求前按添加顺序执行 返回错误时停止启动
+func (e *Engine) OnStart(fn func() error) {
+	e.onStart = append(e.onStart, fn)
+}
+
+// OnShutdown 添加关闭钩子 请求处理完成后按添加顺序执行
+func (e *Engine) OnShutdown(fn func(ctx context.Context) error) {
+	e.onShutdown = append(e.onShutdown, fn)
+}
+
+// RunTLS 以TLS启动服务 证书文件修改后自动重新加载 双向认证使用RunTLSWithOptions
+func (e *Engine) RunTLS(addr, certFile, keyFile string) error {
+	return e.RunTLSWithOptions(addr, TLSOptions{CertFile: certFile, KeyFile: keyFile})
+}
+
+// Run 监听端口 默认:8111 设置了ServerOptions.Listener时使用该监听器
+func (e *Engine) Run(ports ...string) error {
+	port := ":8111"
+	if ports != nil {
+		port = ports[0]
+	}
+	listener, err := e.listen("tcp", port)
+	if err != nil {
+		return err
+	}
+	return e.RunListener(listener)
+}
+
+// RunListener 在指定的监听器上处理请求 服务关闭时监听器随之关闭
+func (e *Engine) RunListener(listener net.Listener) error {
+	return e.serve(listener, e.newServer(true), func(server *http.Server) error {
+		return server.Serve(listener)
+	})
+}
+
+// RunUnix 监听unix socket 启动前删除残留的socket文件 退出后删除socket文件
+func (e *Engine) RunUnix(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	return e.RunListener(listener)
+}
+
+func (e *Engine) listen(network, addr string) (net.Listener, error) {
+	if e.ServerOptions.Listener != nil {
+		return e.ServerOptions.Listener, nil
+	}
+	return net.Listen(network, addr)
+}
+
+// 一次运行的状态 每次启动重新创建 关闭流程只执行一次
+type serverRun struct {
+	server *http.Server
+	once   sync.Once
+	done   chan struct{}
+}
+
+// 启动服务并阻塞 收到SIGINT、SIGTERM或调用Shutdown后等待请求处理完成再返回
+func (e *Engine) serve(listener net.Listener, server *http.Server, serve func(server *http.Server) error) error {
+	e.serverMu.Lock()
+	if e.running != nil {
+		e.serverMu.Unlock()
+		listener.Close()
+		return errors.New("engine is already running")
+	}
+	run := &serverRun{server: server, done: make(chan struct{})}
+	e.running = run
+	e.serverMu.Unlock()
+	// 无论以何种方式退出 都允许再次启动
+	defer e.clearServer(run)
+
+	e.buildChains()
+	if err := e.startRegister(); err != nil {
+		listener.Close()
+		return err
+	}
+	for _, fn := range e.onStart {
+		if err := fn(); err != nil {
+			listener.Close()
+			e.stopRegister()
+			return err
+		}
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	go func() {
+		select {
+		case sig := <-quit:
+			e.Logger.Info("receive signal " + sig.String() + ", shutting down")
+			ctx, cancel := context.WithTimeout(context.Background(), e.shutdownTimeout())
+			defer cancel()
+			if err := e.shutdown(ctx, run); err != nil {
+				e.Logger.Error(err)
+			}
+		case <-run.done:
+		}
+	}()
+
+	err := serve(server)
+	if !errors.Is(err, http.ErrServerClosed) {
+		// 服务异常退出 同样注销服务、执行关闭钩子并结束信号监听
+		ctx, cancel := context.WithTimeout(context.Background(), e.shutdownTimeout())
+		defer cancel()
+		if shutdownErr := e.shutdown(ctx, run); shutdownErr != nil {
+			e.Logger.Error(shutdownErr)
+		}
+		return err
+	}
+	<-run.done
+	return nil
+}
+
+func (e *Engine) shutdownTimeout() time.Duration {
+	if e.ShutdownTimeout <= 0 {
+		return defaultShutdownTimeout
+	}
+	return e.ShutdownTimeout
+}
+
+// 服务退出后清除运行状态
+func (e *Engine) clearServer(run *serverRun) {
+	e.serverMu.Lock()
+	if e.running == run {
+		e.running = nil
+	}
+	e.serverMu.Unlock()
+}
+
+// Shutdown 优雅关闭 先从注册中心注销服务 再停止接收新请求并等待处理中的请求完成
+// 最后执行关闭钩子 ctx超时后不再等待 返回ctx的错误
+func (e *Engine) Shutdown(ctx context.Context) error {
+	e.serverMu.Lock()
+	run := e.running
+	e.serverMu.Unlock()
+	if run == nil {
+		return errors.New("engine is not running")
+	}
+	return e.shutdown(ctx, run)
+}
+
+func (e *Engine) shutdown(ctx context.Context, run *serverRun) error {
+	var err error
+	run.once.Do(func() {
+		defer close(run.done)
+		if e.registerClient != nil && e.RegisterOption.ServiceName != "" {
+			opt := e.RegisterOption
+			if err := e.registerClient.DeregisterService(opt.ServiceName, opt.Host, opt.Port); err != nil {
+				e.Logger.Error(err)
+			}
+		}
+		err = run.server.Shutdown(ctx)
+		for _, fn := range e.onShutdown {
+			if hookErr := fn(ctx); hookErr != nil {
+				e.Logger.Error(hookErr)
+			}
+		}
+		e.stopRegister()
+	})
+	return err
+}
+
+// 创建注册中心客户端 配置了服务名时注册服务
+func (e *Engine) startRegister() error {
+	switch e.RegisterType {
+	case "nacos":
+		e.registerClient = &register.GeeNacosRegister{}
+	case "etcd":
+		e.registerClient = &register.GeeEtcdRegister{}
+	default:
+		return nil
+	}
+	if err := e.registerClient.CreateCli(e.RegisterOption); err != nil {
+		return err
+	}
+	opt := e.RegisterOption
+	if opt.ServiceName != "" {
+		return e.registerClient.RegisterService(opt.ServiceName, opt.Host, opt.Port)
+	}
+	return nil
+}
+
+func (e *Engine) stopRegister() {
+	if e.registerClient == nil {
+		return
+	}
+	if err := e.registerClient.Close(); err != nil {
+		log.Println(err)
+	}
+}
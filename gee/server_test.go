@@ -0,0 +1,153 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEngineShutdown(t *testing.T) {
+	engine := New()
+	started := make(chan struct{}, 1)
+	stopped := 0
+	engine.OnStart(func() error {
+		started <- struct{}{}
+		return nil
+	})
+	engine.OnShutdown(func(ctx context.Context) error {
+		stopped++
+		return nil
+	})
+
+	// 正常关闭后可以再次启动
+	for i := 1; i <= 2; i++ {
+		result := make(chan error, 1)
+		go func() {
+			result <- engine.Run("127.0.0.1:0")
+		}()
+		select {
+		case <-started:
+		case err := <-result:
+			t.Fatalf("run %d: %v", i, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if err := engine.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		if err := <-result; err != nil {
+			t.Fatalf("run %d: Run returned %v after Shutdown", i, err)
+		}
+		if stopped != i {
+			t.Errorf("run %d: OnShutdown called %d times", i, stopped)
+		}
+	}
+	if err := engine.Shutdown(context.Background()); err == nil {
+		t.Error("Shutdown should fail when the engine is not running")
+	}
+}
+
+// Accept总是失败的监听器
+type failingListener struct {
+	net.Listener
+}
+
+func (l failingListener) Accept() (net.Conn, error) {
+	return nil, errors.New("accept failed")
+}
+
+func TestEngineServeError(t *testing.T) {
+	engine := New()
+	stopped := 0
+	engine.OnShutdown(func(ctx context.Context) error {
+		stopped++
+		return nil
+	})
+	for i := 1; i <= 2; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 异常退出后执行关闭流程 并且可以再次启动
+		if err := engine.RunListener(failingListener{listener}); err == nil || err.Error() != "accept failed" {
+			t.Fatalf("run %d: got %v", i, err)
+		}
+		if stopped != i {
+			t.Errorf("run %d: OnShutdown called %d times", i, stopped)
+		}
+	}
+}
+
+func TestEngineRunUnix(t *testing.T) {
+	engine := New()
+	engine.Group("user").Get("/info", func(ctx *Context) {
+		ctx.String(http.StatusOK, "unix")
+	})
+	path := filepath.Join(t.TempDir(), "gee.sock")
+	started := make(chan struct{})
+	engine.OnStart(func() error {
+		close(started)
+		return nil
+	})
+	result := make(chan error, 1)
+	go func() {
+		result <- engine.RunUnix(path)
+	}()
+	select {
+	case <-started:
+	case err := <-result:
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://gee/user/info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "unix" {
+		t.Errorf("got %q, want %q", body, "unix")
+	}
+
+	if err := engine.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket file not removed: %v", err)
+	}
+}
+
+func TestLoadServerConf(t *testing.T) {
+	engine := New()
+	err := engine.loadServerConf(map[string]any{
+		"read_timeout":     "5s",
+		"write_timeout":    int64(10),
+		"max_header_bytes": int64(1 << 20),
+		"h2c":              true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := engine.ServerOptions
+	if opts.ReadTimeout != 5*time.Second || opts.WriteTimeout != 10*time.Second || opts.MaxHeaderBytes != 1<<20 || !opts.H2C {
+		t.Errorf("unexpected options %+v", opts)
+	}
+	if err := engine.loadServerConf(map[string]any{"idle_timeout": "abc"}); err == nil {
+		t.Error("expected error for invalid duration")
+	}
+}
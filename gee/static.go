@@ -0,0 +1,240 @@
+package gee
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaticOptions 静态文件路由的配置
+type StaticOptions struct {
+	// 目录下没有Index文件时是否列出目录内容 默认返回404
+	Browse bool
+	// 目录的默认文件 默认index.html
+	Index string
+	// 单页应用 找不到没有扩展名的路径时返回根目录的Index文件
+	SPA bool
+	// 客户端接受时优先返回预压缩的 .br .gz 文件
+	Precompressed bool
+	// 大于0时设置 Cache-Control: public, max-age=
+	MaxAge time.Duration
+}
+
+// Static 把本地目录挂载到 relativePath 下 /assets -> /assets/*filepath
+func (r *routerGroup) Static(relativePath, root string, opts ...StaticOptions) {
+	r.StaticFS(relativePath, os.DirFS(root), opts...)
+}
+
+// StaticFS 挂载文件系统 可以是embed.FS 子目录用fs.Sub取出
+func (r *routerGroup) StaticFS(relativePath string, fsys fs.FS, opts ...StaticOptions) {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("[路由：" + relativePath + "]静态文件路由中不能有参数")
+	}
+	s := &staticServer{fsys: fsys}
+	if len(opts) > 0 {
+		s.opts = opts[0]
+	}
+	if s.opts.Index == "" {
+		s.opts.Index = "index.html"
+	}
+	r.Get(strings.TrimSuffix(relativePath, SEPARATOR)+"/*filepath", s.serve)
+}
+
+// StaticFile 单个文件的路由 HEAD请求自动支持
+func (r *routerGroup) StaticFile(relativePath, filePath string) {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("[路由：" + relativePath + "]静态文件路由中不能有参数")
+	}
+	r.Get(relativePath, func(ctx *Context) {
+		ctx.File(filePath)
+	})
+}
+
+type staticServer struct {
+	fsys fs.FS
+	opts StaticOptions
+	// 没有修改时间的文件(embed.FS)按内容计算ETag 内容不会变化 缓存起来
+	etags sync.Map
+}
+
+func (s *staticServer) serve(ctx *Context) {
+	urlPath := ctx.Param("filepath")
+	name := strings.TrimPrefix(path.Clean(urlPath), SEPARATOR)
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		ctx.engine.handleNotFound(ctx)
+		return
+	}
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		if s.opts.SPA && path.Ext(name) == "" {
+			s.serveFile(ctx, s.opts.Index)
+			return
+		}
+		ctx.engine.handleNotFound(ctx)
+		return
+	}
+	if !info.IsDir() {
+		s.serveFile(ctx, name)
+		return
+	}
+	// 目录以 / 结尾 保证页面中的相对路径正确
+	if !strings.HasSuffix(urlPath, SEPARATOR) {
+		target := path.Base(ctx.R.URL.Path) + SEPARATOR
+		if ctx.R.URL.RawQuery != "" {
+			target += "?" + ctx.R.URL.RawQuery
+		}
+		ctx.Redirect(http.StatusMovedPermanently, target)
+		return
+	}
+	index := path.Join(name, s.opts.Index)
+	if _, err := fs.Stat(s.fsys, index); err == nil {
+		s.serveFile(ctx, index)
+		return
+	}
+	if s.opts.Browse {
+		s.browse(ctx, name)
+		return
+	}
+	ctx.engine.handleNotFound(ctx)
+}
+
+func (s *staticServer) serveFile(ctx *Context, name string) {
+	header := ctx.W.Header()
+	if s.opts.MaxAge > 0 {
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.opts.MaxAge.Seconds())))
+	}
+	if s.opts.Precompressed {
+		header.Add("Vary", "Accept-Encoding")
+		acceptEncoding := ctx.GetHeader("Accept-Encoding")
+		for _, enc := range []struct{ encoding, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+			if !acceptsEncoding(acceptEncoding, enc.encoding) {
+				continue
+			}
+			if f, info, err := s.open(name + enc.ext); err == nil {
+				defer f.Close()
+				// Content-Type按原文件的扩展名
+				contentType := mime.TypeByExtension(path.Ext(name))
+				if contentType == "" {
+					contentType = "application/octet-stream"
+				}
+				header.Set("Content-Type", contentType)
+				header.Set("Content-Encoding", enc.encoding)
+				s.serveContent(ctx, name+enc.ext, f, info)
+				return
+			}
+		}
+	}
+	f, info, err := s.open(name)
+	if err != nil {
+		ctx.engine.handleNotFound(ctx)
+		return
+	}
+	defer f.Close()
+	s.serveContent(ctx, name, f, info)
+}
+
+func (s *staticServer) open(name string) (fs.File, fs.FileInfo, error) {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, nil, fs.ErrNotExist
+	}
+	return f, info, nil
+}
+
+// 由http.ServeContent处理 If-None-Match、If-Modified-Since、Range和HEAD
+func (s *staticServer) serveContent(ctx *Context, name string, f fs.File, info fs.FileInfo) {
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	etag, err := s.etag(name, content, info)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.W.Header().Set("ETag", etag)
+	http.ServeContent(ctx.W, ctx.R, info.Name(), info.ModTime(), content)
+	ctx.StatusCode = ctx.W.Status()
+}
+
+func (s *staticServer) etag(name string, content io.ReadSeeker, info fs.FileInfo) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano()), nil
+	}
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+func (s *staticServer) browse(ctx *Context, name string) {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += SEPARATOR
+		}
+		u := url.URL{Path: entryName}
+		fmt.Fprintf(&sb, "<a href=\"%s\">%s</a>\n", u.EscapedPath(), html.EscapeString(entryName))
+	}
+	sb.WriteString("</pre>\n")
+	ctx.HTML(http.StatusOK, sb.String())
+}
+
+func acceptsEncoding(acceptEncoding, encoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		// q=0 表示不接受
+		key, value, ok := strings.Cut(strings.TrimSpace(params), "=")
+		if ok && strings.TrimSpace(key) == "q" {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			return err == nil && q > 0
+		}
+		return true
+	}
+	return false
+}
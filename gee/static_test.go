@@ -0,0 +1,98 @@
+package gee
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestStaticFS(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("body{}"))
+	zw.Close()
+	fsys := fstest.MapFS{
+		"index.html":      {Data: []byte("<h1>app</h1>")},
+		"css/main.css":    {Data: []byte("body{}")},
+		"css/main.css.gz": {Data: gz.Bytes()},
+		"docs/a.txt":      {Data: []byte("0123456789")},
+	}
+	engine := New()
+	engine.Group("app").StaticFS("", fsys, StaticOptions{SPA: true, Precompressed: true})
+	engine.Group("docs").StaticFS("/files", fsys, StaticOptions{Browse: true})
+
+	tests := []struct {
+		path   string
+		header map[string]string
+		code   int
+		body   string
+	}{
+		{"/app/", nil, http.StatusOK, "<h1>app</h1>"},
+		{"/app/orders/7", nil, http.StatusOK, "<h1>app</h1>"},
+		{"/app/css/none.css", nil, http.StatusNotFound, ""},
+		{"/app/css/main.css", nil, http.StatusOK, "body{}"},
+		{"/app/css/main.css", map[string]string{"Accept-Encoding": "gzip, br;q=0"}, http.StatusOK, gz.String()},
+		{"/app/../css/main.css", nil, http.StatusOK, "body{}"},
+		{"/docs/files/docs/a.txt", map[string]string{"Range": "bytes=2-4"}, http.StatusPartialContent, "234"},
+		{"/docs/files/docs", nil, http.StatusMovedPermanently, ""},
+		{"/docs/files/docs/", nil, http.StatusOK, "<a href=\"a.txt\">a.txt</a>"},
+		{"/app/docs/", nil, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s %v: got %d %q", tt.path, tt.header, w.Code, w.Body.String())
+		}
+	}
+
+	// 预压缩文件的类型按原文件 并提示缓存按编码区分
+	r := httptest.NewRequest(http.MethodGet, "/app/css/main.css", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("precompressed headers: %v", w.Header())
+	}
+}
+
+func TestStaticCache(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(1)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine := New()
+	engine.Group("static").Static("", dir, StaticOptions{MaxAge: time.Hour})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/app.js", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Last-Modified") == "" || w.Header().Get("Cache-Control") != "public, max-age=3600" {
+		t.Fatalf("got %d %v", w.Code, w.Header())
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/static/app.js", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/static/app.js", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("Content-Length") != "14" {
+		t.Errorf("HEAD: got %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+}
@@ -0,0 +1,75 @@
+package gee
+
+import (
+	"html"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/gee-coder/gee/render"
+)
+
+// 模板函数先输出占位符 渲染完成后替换为本次请求的值
+// 占位符带有进程启动时生成的随机数 用户提交的内容无法伪造
+var (
+	markerPrefix    = "gee-" + newRequestID() + "-"
+	cspNonceMarker  = markerPrefix + "csp-nonce"
+	csrfTokenMarker = markerPrefix + "csrf-token"
+	csrfFieldMarker = markerPrefix + "csrf-field"
+)
+
+// TemplateFuncs 内置的模板函数 自行解析模板后通过SetHtmlTemplate设置时需要加入
+// 只在使用了Secure或CSRF中间件的请求中替换 其他请求中的模板不应调用
+//
+//	cspNonce  Secure中间件生成的CSP nonce <script nonce="{{ cspNonce }}">
+//	csrfToken CSRF中间件生成的token
+//	csrfField 携带token的隐藏表单字段
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"cspNonce":  func() string { return cspNonceMarker },
+		"csrfToken": func() string { return csrfTokenMarker },
+		"csrfField": func() template.HTML { return template.HTML(csrfFieldMarker) },
+	}
+}
+
+// 内置模板函数加上SetFuncMap设置的 同名时使用设置的
+func (e *Engine) templateFuncs(funcMap template.FuncMap) template.FuncMap {
+	funcs := TemplateFuncs()
+	for name, fn := range funcMap {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// 替换模板输出中的占位符 没有使用Secure和CSRF中间件时返回nil 直接输出模板
+// 只使用了其中一个时 另一个的占位符替换为空
+func (c *Context) templateReplacer() render.StringReplacer {
+	if c.cspNonce == "" && c.csrfToken == "" {
+		return nil
+	}
+	return markerReplacer{c}
+}
+
+// 输出中有占位符时才创建替换器 每个请求只创建一次
+type markerReplacer struct {
+	c *Context
+}
+
+func (m markerReplacer) WriteString(w io.Writer, s string) (int, error) {
+	if !strings.Contains(s, markerPrefix) {
+		return io.WriteString(w, s)
+	}
+	c := m.c
+	if c.replacer == nil {
+		field := ""
+		if c.csrfToken != "" {
+			field = `<input type="hidden" name="` + html.EscapeString(c.csrfFieldName) + `" value="` + c.csrfToken + `">`
+		}
+		c.replacer = strings.NewReplacer(
+			cspNonceMarker, c.cspNonce,
+			csrfTokenMarker, c.csrfToken,
+			csrfFieldMarker, field,
+		)
+	}
+	return c.replacer.WriteString(w, s)
+}
@@ -0,0 +1,181 @@
+package gee
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// 默认检查证书文件变化的间隔
+const defaultCertReloadInterval = 30 * time.Second
+
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// 客户端CA证书 设置后开启双向认证 要求客户端提供由该CA签发的证书
+	ClientCAFile string
+	// 检查证书文件变化的间隔 文件修改后重新加载 不需要重启服务
+	// 0使用默认的30秒 负数表示不重新加载
+	ReloadInterval time.Duration
+}
+
+// RunTLSWithOptions 以TLS启动服务 支持双向认证和证书热加载
+func (e *Engine) RunTLSWithOptions(addr string, opts TLSOptions) error {
+	reloader, err := newCertReloader(opts)
+	if err != nil {
+		return err
+	}
+	listener, err := e.listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	interval := opts.ReloadInterval
+	if interval == 0 {
+		interval = defaultCertReloadInterval
+	}
+	if interval > 0 {
+		go reloader.watch(interval, done, e)
+	}
+	server := e.newServer(false)
+	server.TLSConfig = reloader.tlsConfig()
+	return e.serve(listener, server, func(server *http.Server) error {
+		return server.ServeTLS(listener, "", "")
+	})
+}
+
+// PeerCertificate 双向认证时返回校验通过的客户端证书 否则返回nil
+func (c *Context) PeerCertificate() *x509.Certificate {
+	if c.R.TLS == nil || len(c.R.TLS.VerifiedChains) == 0 || len(c.R.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return c.R.TLS.VerifiedChains[0][0]
+}
+
+// 从磁盘加载证书 文件修改时间变化后重新加载
+type certReloader struct {
+	opts      TLSOptions
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+func newCertReloader(opts TLSOptions) (*certReloader, error) {
+	r := &certReloader{opts: opts}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.opts.CertFile, r.opts.KeyFile}
+	if r.opts.ClientCAFile != "" {
+		files = append(files, r.opts.ClientCAFile)
+	}
+	return files
+}
+
+func (r *certReloader) reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+	var clientCAs *x509.CertPool
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("no valid certificate in " + r.opts.ClientCAFile)
+		}
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false
+		}
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// 加载失败时继续使用原来的证书
+func (r *certReloader) watch(interval time.Duration, done <-chan struct{}, e *Engine) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				e.Logger.Error("reload certificate fail: " + err.Error())
+				continue
+			}
+			e.Logger.Info("certificate reloaded")
+		}
+	}
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// 每次握手时读取当前的证书和客户端CA
+func (r *certReloader) tlsConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: r.getCertificate,
+	}
+	if r.opts.ClientCAFile == "" {
+		return config
+	}
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			NextProtos:     []string{"h2", "http/1.1"},
+			GetCertificate: r.getCertificate,
+			ClientAuth:     tls.RequireAndVerifyClientCert,
+			ClientCAs:      r.clientCAs,
+		}, nil
+	}
+	return config
+}
@@ -0,0 +1,21 @@
+package token
+
+import (
+	"fmt"
+
+	"github.com/gee-coder/gee"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// KeyBySubject 按jwt的sub限流 需要放在AuthInterceptor之后
+// 没有登录信息时按客户端IP
+func KeyBySubject(ctx *gee.Context) string {
+	if v, ok := ctx.Get("jwt_claims"); ok {
+		if claims, ok := v.(jwt.MapClaims); ok {
+			if sub, ok := claims["sub"]; ok && sub != nil {
+				return fmt.Sprintf("sub:%v", sub)
+			}
+		}
+	}
+	return gee.KeyByIP(ctx)
+}
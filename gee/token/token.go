@@ -247,8 +247,11 @@ func (j *JwtHandler) AuthInterceptor(next gee.HandlerFunc) gee.HandlerFunc {
 }
 
 func (j *JwtHandler) AuthErrorHandler(ctx *gee.Context, err error) {
+	ctx.Abort()
 	if j.AuthHandler == nil {
-		ctx.W.WriteHeader(http.StatusUnauthorized)
+		// 解析失败的原因只记录日志 不返回给客户端
+		ctx.Logger.Info("jwt auth: " + err.Error())
+		ctx.Error(gee.NewProblem(http.StatusUnauthorized, "missing or invalid token"))
 	} else {
 		j.AuthHandler(ctx, err)
 	}
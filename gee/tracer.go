@@ -30,7 +30,7 @@ func Tracer(serviceName string, samplerConfig *config.SamplerConfig, reporter *c
 			ctx.R = ctx.R.WithContext(opentracing.ContextWithSpan(ctx.R.Context(), startSpan))
 			next(ctx)
 			// 继续设置 tag
-			ext.HTTPStatusCode.Set(startSpan, uint16(ctx.StatusCode))
+			ext.HTTPStatusCode.Set(startSpan, uint16(ctx.W.Status()))
 		}
 	}
 }
@@ -0,0 +1,38 @@
+package gee
+
+import (
+	"net/http"
+
+	"github.com/gee-coder/gee/websocket"
+)
+
+// WebSocketHandler 握手成功后调用 返回后连接会被关闭
+type WebSocketHandler func(ctx *Context, conn *websocket.Conn)
+
+// UpgradeWebSocket 按Engine.WebSocketUpgrader的配置把当前请求升级成WebSocket连接
+// 失败时已经向客户端写入了错误响应
+func (c *Context) UpgradeWebSocket(responseHeader http.Header) (*websocket.Conn, error) {
+	conn, err := c.engine.WebSocketUpgrader.Upgrade(c.W, c.R, responseHeader)
+	if err != nil {
+		c.StatusCode = c.W.Status()
+		return nil, err
+	}
+	// 连接已被接管 记录101供日志等中间件使用
+	c.writer.status = http.StatusSwitchingProtocols
+	c.StatusCode = http.StatusSwitchingProtocols
+	return conn, nil
+}
+
+// WebSocket 注册WebSocket路由 中间件在握手之前执行 可以用来鉴权
+func (r *routerGroup) WebSocket(routerName string, handler WebSocketHandler, middlewareFunc ...MiddlewareFunc) {
+	r.handle(routerName, http.MethodGet, func(ctx *Context) {
+		conn, err := ctx.UpgradeWebSocket(nil)
+		if err != nil {
+			ctx.Logger.Error(err)
+			return
+		}
+		defer conn.Close()
+		handler(ctx, conn)
+		conn.WriteClose(websocket.CloseNormalClosure, "")
+	}, middlewareFunc...)
+}
@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+)
+
+// permessage-deflate RFC 7692
+// 双方都不保留压缩上下文 每条消息单独压缩
+
+const defaultCompressionLevel = flate.BestSpeed
+
+// 同步刷新产生的空块 发送时去掉 接收时补上
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// 补在末尾的结束块 让flate.Reader正常返回io.EOF
+var deflateFinalBlock = []byte{0x01, 0x00, 0x00, 0xff, 0xff}
+
+func isValidCompressionLevel(level int) bool {
+	return flate.HuffmanOnly <= level && level <= flate.BestCompression
+}
+
+func compressData(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := fw.Flush(); err != nil {
+		return nil, err
+	}
+	compressed := buf.Bytes()
+	if !bytes.HasSuffix(compressed, deflateTail) {
+		return nil, errors.New("websocket: unexpected deflate output")
+	}
+	return compressed[:len(compressed)-len(deflateTail)], nil
+}
+
+func decompressData(data []byte, limit int64) ([]byte, error) {
+	fr := flate.NewReader(io.MultiReader(
+		bytes.NewReader(data),
+		bytes.NewReader(deflateTail),
+		bytes.NewReader(deflateFinalBlock),
+	))
+	defer fr.Close()
+	out, err := io.ReadAll(io.LimitReader(fr, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > limit {
+		return nil, ErrReadLimit
+	}
+	return out, nil
+}
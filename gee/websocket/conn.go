@@ -0,0 +1,528 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 消息类型 即RFC 6455中的opcode
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+const continuationFrame = 0
+
+// 关闭码 RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	finalBit = 1 << 7
+	rsv1Bit  = 1 << 6
+	rsv2Bit  = 1 << 5
+	rsv3Bit  = 1 << 4
+	maskBit  = 1 << 7
+
+	maxControlFramePayloadSize = 125
+	// 单条消息默认最大32M
+	defaultReadLimit = 32 << 20
+)
+
+var (
+	// 已经发送过关闭帧 不能再写入
+	ErrCloseSent = errors.New("websocket: close sent")
+	// 消息超过SetReadLimit设置的大小
+	ErrReadLimit = errors.New("websocket: read limit exceeded")
+)
+
+// CloseError 对端发送的关闭帧
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// IsCloseError err是对端发送的关闭帧并且关闭码在codes中
+func IsCloseError(err error, codes ...int) bool {
+	var e *CloseError
+	if !errors.As(err, &e) {
+		return false
+	}
+	for _, code := range codes {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn WebSocket连接
+// 读方法只能在一个协程中调用 写方法可以并发调用
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	isServer    bool
+	subprotocol string
+	// 握手时协商了permessage-deflate
+	compress         bool
+	compressionLevel int
+	writeCompress    bool
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	readLimit int64
+	// 读出错后连接不可再读 后续读取都返回这个错误
+	readErr error
+
+	pingHandler  func(appData string) error
+	pongHandler  func(appData string) error
+	closeHandler func(code int, text string) error
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, isServer bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	c := &Conn{
+		conn:             conn,
+		br:               br,
+		isServer:         isServer,
+		compressionLevel: defaultCompressionLevel,
+		writeCompress:    true,
+		readLimit:        defaultReadLimit,
+	}
+	c.SetPingHandler(nil)
+	c.SetCloseHandler(nil)
+	return c
+}
+
+// Subprotocol 握手时协商的子协议
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// NetConn 底层的网络连接
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetReadLimit 单条消息的最大字节数 超过时发送1009关闭帧
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetCompressionLevel 压缩级别 同compress/flate
+func (c *Conn) SetCompressionLevel(level int) error {
+	if !isValidCompressionLevel(level) {
+		return errors.New("websocket: invalid compression level")
+	}
+	c.compressionLevel = level
+	return nil
+}
+
+// EnableWriteCompression 协商了permessage-deflate时 后续消息是否压缩 默认压缩
+func (c *Conn) EnableWriteCompression(enable bool) {
+	c.writeCompress = enable
+}
+
+// SetPingHandler 收到ping时调用 为nil时回复相同内容的pong
+func (c *Conn) SetPingHandler(h func(appData string) error) {
+	if h == nil {
+		h = func(appData string) error {
+			err := c.WriteControl(PongMessage, []byte(appData))
+			if errors.Is(err, ErrCloseSent) {
+				return nil
+			}
+			return err
+		}
+	}
+	c.pingHandler = h
+}
+
+// SetPongHandler 收到pong时调用 常用来延长读超时
+func (c *Conn) SetPongHandler(h func(appData string) error) {
+	c.pongHandler = h
+}
+
+// SetCloseHandler 收到关闭帧时调用 为nil时回复相同关闭码的关闭帧
+func (c *Conn) SetCloseHandler(h func(code int, text string) error) {
+	if h == nil {
+		h = func(code int, text string) error {
+			var payload []byte
+			if code != CloseNoStatusReceived {
+				payload = FormatCloseMessage(code, "")
+			}
+			// 对端可能已经断开 回复失败不影响关闭
+			c.WriteControl(CloseMessage, payload)
+			return nil
+		}
+	}
+	c.closeHandler = h
+}
+
+// FormatCloseMessage 关闭帧的内容 关闭码加原因
+func FormatCloseMessage(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return []byte{}
+	}
+	// 控制帧最多125字节 去掉关闭码的2字节
+	if len(text) > maxControlFramePayloadSize-2 {
+		text = text[:maxControlFramePayloadSize-2]
+	}
+	buf := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(buf, uint16(code))
+	copy(buf[2:], text)
+	return buf
+}
+
+// Close 直接关闭底层连接 不发送关闭帧
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// WriteClose 发送关闭帧 之后只能读取 不能再写入
+func (c *Conn) WriteClose(code int, text string) error {
+	return c.WriteControl(CloseMessage, FormatCloseMessage(code, text))
+}
+
+// WriteControl 发送ping、pong或关闭帧
+func (c *Conn) WriteControl(messageType int, data []byte) error {
+	if !isControl(messageType) {
+		return errors.New("websocket: bad control message type")
+	}
+	if len(data) > maxControlFramePayloadSize {
+		return errors.New("websocket: control frame too long")
+	}
+	return c.writeFrame(messageType, false, data)
+}
+
+// WriteMessage 发送一条文本或二进制消息
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if isControl(messageType) {
+		return c.WriteControl(messageType, data)
+	}
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return errors.New("websocket: bad message type")
+	}
+	if c.compress && c.writeCompress {
+		compressed, err := compressData(data, c.compressionLevel)
+		if err != nil {
+			return err
+		}
+		return c.writeFrame(messageType, true, compressed)
+	}
+	return c.writeFrame(messageType, false, data)
+}
+
+func (c *Conn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// 消息不分片 整条作为一个帧发送
+func (c *Conn) writeFrame(opcode int, compressed bool, payload []byte) error {
+	header := make([]byte, 2, 14)
+	header[0] = finalBit | byte(opcode)
+	if compressed {
+		header[0] |= rsv1Bit
+	}
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+	// 客户端发送的帧必须掩码
+	if !c.isServer {
+		header[1] |= maskBit
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		header = append(header, key[:]...)
+		masked := make([]byte, length)
+		copy(masked, payload)
+		maskBytes(key, masked)
+		payload = masked
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+	buffers := net.Buffers{header, payload}
+	_, err := buffers.WriteTo(c.conn)
+	return err
+}
+
+// ReadMessage 读取一条完整的消息 分片的消息会被合并
+// ping、pong、关闭帧交给对应的handler处理 收到关闭帧时返回*CloseError
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	messageType, data, err = c.readMessage()
+	if err != nil {
+		c.readErr = err
+	}
+	return messageType, data, err
+}
+
+func (c *Conn) ReadJSON(v any) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+type frameHeader struct {
+	fin        bool
+	compressed bool
+	opcode     int
+	length     int64
+	masked     bool
+	maskKey    [4]byte
+}
+
+func (c *Conn) readMessage() (int, []byte, error) {
+	messageType := 0
+	compressed := false
+	var data []byte
+	for {
+		h, err := c.readFrameHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+		if isControl(h.opcode) {
+			payload, err := c.readPayload(h)
+			if err != nil {
+				return 0, nil, err
+			}
+			if err := c.handleControl(h.opcode, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+		if h.opcode == continuationFrame {
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "continuation frame without start")
+			}
+		} else {
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "new message before previous message finished")
+			}
+			messageType = h.opcode
+			compressed = h.compressed
+		}
+		if int64(len(data))+h.length > c.readLimit {
+			c.fail(CloseMessageTooBig, "")
+			return 0, nil, ErrReadLimit
+		}
+		payload, err := c.readPayload(h)
+		if err != nil {
+			return 0, nil, err
+		}
+		data = append(data, payload...)
+		if h.fin {
+			break
+		}
+	}
+	if compressed {
+		var err error
+		data, err = decompressData(data, c.readLimit)
+		if errors.Is(err, ErrReadLimit) {
+			c.fail(CloseMessageTooBig, "")
+			return 0, nil, err
+		}
+		if err != nil {
+			return 0, nil, c.fail(CloseProtocolError, "invalid compressed data")
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.fail(CloseInvalidFramePayloadData, "invalid utf8 payload")
+	}
+	return messageType, data, nil
+}
+
+func (c *Conn) readFrameHeader() (frameHeader, error) {
+	var h frameHeader
+	var buf [8]byte
+	if _, err := io.ReadFull(c.br, buf[:2]); err != nil {
+		return h, err
+	}
+	h.fin = buf[0]&finalBit != 0
+	h.compressed = buf[0]&rsv1Bit != 0
+	h.opcode = int(buf[0] & 0xf)
+	h.masked = buf[1]&maskBit != 0
+	h.length = int64(buf[1] & 0x7f)
+
+	if buf[0]&(rsv2Bit|rsv3Bit) != 0 {
+		return h, c.fail(CloseProtocolError, "unexpected reserved bits")
+	}
+	switch h.opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+		// 只有消息的第一帧可以设置压缩位
+		if h.compressed && (!c.compress || h.opcode == continuationFrame) {
+			return h, c.fail(CloseProtocolError, "unexpected compressed frame")
+		}
+	case CloseMessage, PingMessage, PongMessage:
+		if !h.fin || h.compressed || h.length > maxControlFramePayloadSize {
+			return h, c.fail(CloseProtocolError, "invalid control frame")
+		}
+	default:
+		return h, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", h.opcode))
+	}
+	// 客户端发送的帧必须掩码 服务端发送的帧不能掩码
+	if h.masked != c.isServer {
+		return h, c.fail(CloseProtocolError, "incorrect mask flag")
+	}
+
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(c.br, buf[:2]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(buf[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, buf[:8]); err != nil {
+			return h, err
+		}
+		length := binary.BigEndian.Uint64(buf[:8])
+		if length>>63 != 0 {
+			return h, c.fail(CloseProtocolError, "invalid frame length")
+		}
+		h.length = int64(length)
+	}
+	if h.masked {
+		if _, err := io.ReadFull(c.br, h.maskKey[:]); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+func (c *Conn) readPayload(h frameHeader) ([]byte, error) {
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return nil, err
+	}
+	if h.masked {
+		maskBytes(h.maskKey, payload)
+	}
+	return payload, nil
+}
+
+func (c *Conn) handleControl(opcode int, payload []byte) error {
+	switch opcode {
+	case PingMessage:
+		return c.pingHandler(string(payload))
+	case PongMessage:
+		if c.pongHandler != nil {
+			return c.pongHandler(string(payload))
+		}
+		return nil
+	}
+	code := CloseNoStatusReceived
+	text := ""
+	if len(payload) == 1 {
+		return c.fail(CloseProtocolError, "invalid close payload")
+	}
+	if len(payload) >= 2 {
+		code = int(binary.BigEndian.Uint16(payload))
+		if !isValidReceivedCloseCode(code) {
+			return c.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.Valid(payload[2:]) {
+			return c.fail(CloseInvalidFramePayloadData, "invalid utf8 close reason")
+		}
+		text = string(payload[2:])
+	}
+	if err := c.closeHandler(code, text); err != nil {
+		return err
+	}
+	return &CloseError{Code: code, Text: text}
+}
+
+// 发送关闭帧 返回读取失败的原因
+func (c *Conn) fail(code int, text string) error {
+	c.WriteClose(code, text)
+	return errors.New("websocket: " + text)
+}
+
+func isControl(opcode int) bool {
+	return opcode == CloseMessage || opcode == PingMessage || opcode == PongMessage
+}
+
+// 1004、1005、1006、1015不能出现在关闭帧中
+func isValidReceivedCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
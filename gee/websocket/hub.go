@@ -0,0 +1,135 @@
+package websocket
+
+import "sync"
+
+// 每个连接默认缓存的待发送消息数
+const defaultHubQueueSize = 256
+
+type hubMessage struct {
+	messageType int
+	data        []byte
+}
+
+type hubClient struct {
+	send chan hubMessage
+}
+
+// Hub 管理一组连接并向它们广播消息
+// 每个连接有独立的发送队列和写协程 慢连接不会阻塞广播 队列满时会被断开
+type Hub struct {
+	mu        sync.RWMutex
+	clients   map[*Conn]*hubClient
+	queueSize int
+	closed    bool
+}
+
+// NewHub queueSize为每个连接的发送队列长度 不大于0时使用默认值256
+func NewHub(queueSize int) *Hub {
+	if queueSize <= 0 {
+		queueSize = defaultHubQueueSize
+	}
+	return &Hub{
+		clients:   make(map[*Conn]*hubClient),
+		queueSize: queueSize,
+	}
+}
+
+// Register 加入连接 Hub关闭后加入的连接会被直接关闭
+func (h *Hub) Register(conn *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		conn.WriteClose(CloseGoingAway, "")
+		conn.Close()
+		return
+	}
+	if _, ok := h.clients[conn]; ok {
+		return
+	}
+	client := &hubClient{send: make(chan hubMessage, h.queueSize)}
+	h.clients[conn] = client
+	go h.writeLoop(conn, client)
+}
+
+// Unregister 移除连接 不会关闭连接
+func (h *Hub) Unregister(conn *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(conn)
+}
+
+func (h *Hub) remove(conn *Conn) {
+	if client, ok := h.clients[conn]; ok {
+		delete(h.clients, conn)
+		close(client.send)
+	}
+}
+
+func (h *Hub) writeLoop(conn *Conn, client *hubClient) {
+	for msg := range client.send {
+		if err := conn.WriteMessage(msg.messageType, msg.data); err != nil {
+			h.Unregister(conn)
+			conn.Close()
+			// 排空队列 让Unregister关闭的通道正常结束
+			for range client.send {
+			}
+			return
+		}
+	}
+}
+
+// Broadcast 向所有连接发送消息
+func (h *Hub) Broadcast(messageType int, data []byte) {
+	h.BroadcastFilter(messageType, data, nil)
+}
+
+// BroadcastFilter 向filter返回true的连接发送消息 filter为nil时发送给所有连接
+func (h *Hub) BroadcastFilter(messageType int, data []byte, filter func(conn *Conn) bool) {
+	msg := hubMessage{messageType: messageType, data: data}
+	slow := make([]*Conn, 0)
+	h.mu.RLock()
+	for conn, client := range h.clients {
+		if filter != nil && !filter(conn) {
+			continue
+		}
+		select {
+		case client.send <- msg:
+		default:
+			slow = append(slow, conn)
+		}
+	}
+	h.mu.RUnlock()
+	if len(slow) == 0 {
+		return
+	}
+	h.mu.Lock()
+	for _, conn := range slow {
+		h.remove(conn)
+		// 关闭连接 让读取该连接的处理方法退出
+		conn.Close()
+	}
+	h.mu.Unlock()
+}
+
+// Len 当前连接数
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Close 向所有连接发送1001关闭帧并移除 服务关闭时可在OnShutdown中调用
+// http.Server.Shutdown不会等待已升级的连接
+func (h *Hub) Close() {
+	h.mu.Lock()
+	conns := make([]*Conn, 0, len(h.clients))
+	for conn := range h.clients {
+		h.remove(conn)
+		conns = append(conns, conn)
+	}
+	h.closed = true
+	h.mu.Unlock()
+	for _, conn := range conns {
+		conn.WriteClose(CloseGoingAway, "")
+	}
+}
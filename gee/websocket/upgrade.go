@@ -0,0 +1,219 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 拼接在Sec-WebSocket-Key后计算Sec-WebSocket-Accept
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// HandshakeError 握手失败 已经向客户端返回了错误响应
+type HandshakeError struct {
+	Code    int
+	Message string
+}
+
+func (e HandshakeError) Error() string {
+	return "websocket: " + e.Message
+}
+
+// Upgrader 把HTTP请求升级成WebSocket连接
+type Upgrader struct {
+	// 写入握手响应的超时时间 0表示不限制
+	HandshakeTimeout time.Duration
+	// 读缓冲区大小 0表示使用http服务的缓冲区
+	ReadBufferSize int
+	// 服务端支持的子协议 按优先级排列
+	Subprotocols []string
+	// 校验Origin 为nil时要求Origin与Host一致
+	CheckOrigin func(r *http.Request) bool
+	// 客户端支持时启用permessage-deflate
+	EnableCompression bool
+	// 单条消息的最大字节数 0表示默认32M
+	ReadLimit int64
+}
+
+func (u *Upgrader) returnError(w http.ResponseWriter, code int, msg string) error {
+	w.Header().Set("Sec-WebSocket-Version", "13")
+	http.Error(w, http.StatusText(code), code)
+	return HandshakeError{Code: code, Message: msg}
+}
+
+// Upgrade 完成握手并接管连接 responseHeader会附加到101响应中
+// 失败时已经写入了错误响应 调用方不需要再处理
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, u.returnError(w, http.StatusMethodNotAllowed, "request method is not GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") {
+		return nil, u.returnError(w, http.StatusBadRequest, "'upgrade' token not found in 'Connection' header")
+	}
+	if !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, u.returnError(w, http.StatusBadRequest, "'websocket' token not found in 'Upgrade' header")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, u.returnError(w, http.StatusUpgradeRequired, "unsupported version")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = checkSameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, u.returnError(w, http.StatusForbidden, "request origin not allowed")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, u.returnError(w, http.StatusBadRequest, "invalid 'Sec-WebSocket-Key' header")
+	}
+
+	subprotocol := responseHeader.Get("Sec-WebSocket-Protocol")
+	if subprotocol == "" {
+		subprotocol = u.selectSubprotocol(r)
+	}
+	compress := u.EnableCompression && acceptDeflate(r.Header)
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, u.returnError(w, http.StatusInternalServerError, err.Error())
+	}
+	// 清掉http服务设置的读写超时
+	netConn.SetDeadline(time.Time{})
+
+	var sb strings.Builder
+	sb.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	sb.WriteString(computeAcceptKey(key))
+	sb.WriteString("\r\n")
+	if subprotocol != "" {
+		sb.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		sb.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	for k, values := range responseHeader {
+		if k == "Sec-Websocket-Protocol" || k == "Sec-Websocket-Extensions" {
+			continue
+		}
+		for _, v := range values {
+			sb.WriteString(k + ": " + strings.NewReplacer("\r", "", "\n", "").Replace(v) + "\r\n")
+		}
+	}
+	sb.WriteString("\r\n")
+
+	if u.HandshakeTimeout > 0 {
+		netConn.SetWriteDeadline(time.Now().Add(u.HandshakeTimeout))
+	}
+	if _, err := netConn.Write([]byte(sb.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if u.HandshakeTimeout > 0 {
+		netConn.SetWriteDeadline(time.Time{})
+	}
+
+	br := brw.Reader
+	if u.ReadBufferSize > 0 {
+		br = bufio.NewReaderSize(br, u.ReadBufferSize)
+	}
+	c := newConn(netConn, br, true)
+	c.subprotocol = subprotocol
+	c.compress = compress
+	if u.ReadLimit > 0 {
+		c.readLimit = u.ReadLimit
+	}
+	return c, nil
+}
+
+func (u *Upgrader) selectSubprotocol(r *http.Request) string {
+	offered := parseTokens(r.Header, "Sec-WebSocket-Protocol")
+	for _, s := range u.Subprotocols {
+		for _, o := range offered {
+			if s == o {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// IsWebSocketUpgrade 请求是否为WebSocket握手
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+func computeAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func checkSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// 逗号分隔的头部值 如 Connection: keep-alive, Upgrade
+func parseTokens(header http.Header, name string) []string {
+	tokens := make([]string, 0)
+	for _, value := range header.Values(name) {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, t := range parseTokens(header, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// 客户端提供的permessage-deflate参数都能接受时启用压缩
+// server_max_window_bits小于15时 compress/flate无法满足 不启用
+func acceptDeflate(header http.Header) bool {
+	for _, ext := range parseTokens(header, "Sec-WebSocket-Extensions") {
+		params := strings.Split(ext, ";")
+		if strings.TrimSpace(params[0]) != "permessage-deflate" {
+			continue
+		}
+		if deflateParamsSupported(params[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func deflateParamsSupported(params []string) bool {
+	for _, param := range params {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch strings.TrimSpace(name) {
+		case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+		case "server_max_window_bits":
+			if strings.Trim(strings.TrimSpace(value), `"`) != "15" {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
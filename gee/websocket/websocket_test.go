@@ -0,0 +1,227 @@
+package websocket
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试用的客户端 手动完成握手
+func dial(t *testing.T, server *httptest.Server, header http.Header) (*Conn, *http.Response) {
+	t.Helper()
+	netConn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(netConn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		netConn.Close()
+		return nil, resp
+	}
+	c := newConn(netConn, br, false)
+	c.compress = strings.HasPrefix(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+	t.Cleanup(func() { c.Close() })
+	return c, resp
+}
+
+func echoServer(t *testing.T, upgrader *Upgrader) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, data); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHandshake(t *testing.T) {
+	server := echoServer(t, &Upgrader{Subprotocols: []string{"chat", "json"}, EnableCompression: true})
+
+	_, resp := dial(t, server, http.Header{
+		"Sec-Websocket-Protocol":   {"json, chat"},
+		"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"},
+	})
+	// RFC 6455 1.3 中的示例
+	if resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("accept key: got %q", resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	if resp.Header.Get("Sec-WebSocket-Protocol") != "chat" {
+		t.Errorf("subprotocol: got %q", resp.Header.Get("Sec-WebSocket-Protocol"))
+	}
+	if !strings.HasPrefix(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		t.Errorf("extensions: got %q", resp.Header.Get("Sec-WebSocket-Extensions"))
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		code   int
+	}{
+		{"bad version", http.Header{"Sec-Websocket-Version": {"8"}}, http.StatusUpgradeRequired},
+		{"bad key", http.Header{"Sec-Websocket-Key": {"short"}}, http.StatusBadRequest},
+		{"cross origin", http.Header{"Origin": {"http://evil.example"}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if _, resp := dial(t, server, tt.header); resp.StatusCode != tt.code {
+			t.Errorf("%s: got %d, want %d", tt.name, resp.StatusCode, tt.code)
+		}
+	}
+}
+
+func TestEcho(t *testing.T) {
+	server := echoServer(t, &Upgrader{EnableCompression: true})
+	for _, ext := range []string{"", "permessage-deflate"} {
+		conn, _ := dial(t, server, http.Header{"Sec-Websocket-Extensions": {ext}})
+		if conn.compress != (ext != "") {
+			t.Fatalf("%q: compress=%v", ext, conn.compress)
+		}
+		messages := []string{"hello", "", strings.Repeat("订单", 40000)}
+		for _, msg := range messages {
+			if err := conn.WriteMessage(TextMessage, []byte(msg)); err != nil {
+				t.Fatal(err)
+			}
+			messageType, data, err := conn.ReadMessage()
+			if err != nil || messageType != TextMessage || string(data) != msg {
+				t.Errorf("%q: got %d %d bytes %v", ext, messageType, len(data), err)
+			}
+		}
+	}
+}
+
+func TestControlFrames(t *testing.T) {
+	server := echoServer(t, &Upgrader{})
+	conn, _ := dial(t, server, nil)
+
+	// 分片消息中间插入ping
+	conn.writeRaw(t, TextMessage, false, []byte("hel"))
+	conn.writeRaw(t, PingMessage, true, []byte("p"))
+	conn.writeRaw(t, continuationFrame, true, []byte("lo"))
+	pong := make(chan string, 1)
+	conn.SetPongHandler(func(appData string) error {
+		pong <- appData
+		return nil
+	})
+	_, data, err := conn.ReadMessage()
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("fragmented: got %q %v", data, err)
+	}
+	select {
+	case p := <-pong:
+		if p != "p" {
+			t.Errorf("pong: got %q", p)
+		}
+	case <-time.After(time.Second):
+		t.Error("pong not received")
+	}
+
+	if err := conn.WriteClose(CloseGoingAway, "bye"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := conn.ReadMessage(); !IsCloseError(err, CloseGoingAway) {
+		t.Errorf("close: got %v", err)
+	}
+	if err := conn.WriteMessage(TextMessage, []byte("x")); err != ErrCloseSent {
+		t.Errorf("write after close: got %v", err)
+	}
+}
+
+func TestInvalidPayload(t *testing.T) {
+	server := echoServer(t, &Upgrader{ReadLimit: 8})
+	tests := []struct {
+		name    string
+		opcode  int
+		payload []byte
+		code    int
+	}{
+		{"invalid utf8", TextMessage, []byte{0xff, 0xfe}, CloseInvalidFramePayloadData},
+		{"too big", BinaryMessage, []byte("0123456789"), CloseMessageTooBig},
+		{"unknown opcode", 3, nil, CloseProtocolError},
+	}
+	for _, tt := range tests {
+		conn, _ := dial(t, server, nil)
+		conn.writeRaw(t, tt.opcode, true, tt.payload)
+		if _, _, err := conn.ReadMessage(); !IsCloseError(err, tt.code) {
+			t.Errorf("%s: got %v, want close %d", tt.name, err, tt.code)
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	hub := NewHub(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.Register(conn)
+		defer hub.Unregister(conn)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			hub.Broadcast(TextMessage, data)
+		}
+	}))
+	defer server.Close()
+
+	a, _ := dial(t, server, nil)
+	b, _ := dial(t, server, nil)
+	for hub.Len() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	a.WriteMessage(TextMessage, []byte("paid"))
+	for _, conn := range []*Conn{a, b} {
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != "paid" {
+			t.Errorf("broadcast: got %q %v", data, err)
+		}
+	}
+	hub.Close()
+	if _, _, err := b.ReadMessage(); !IsCloseError(err, CloseGoingAway) {
+		t.Errorf("hub close: got %v", err)
+	}
+}
+
+// 绕过WriteMessage的校验 直接写入一帧
+func (c *Conn) writeRaw(t *testing.T, opcode int, fin bool, payload []byte) {
+	t.Helper()
+	frame := []byte{byte(opcode), maskBit | byte(len(payload)), 1, 2, 3, 4}
+	if fin {
+		frame[0] |= finalBit
+	}
+	masked := append([]byte(nil), payload...)
+	maskBytes([4]byte{1, 2, 3, 4}, masked)
+	if _, err := c.conn.Write(append(frame, masked...)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -16,6 +16,7 @@ import (
 func main() {
 
 	engine := gee.Default()
+	engine.AddMiddlewareFunc(gee.RequestID)
 	group := engine.Group("goods")
 	group.Get("/find", func(ctx *gee.Context) {
 		goods := &model.Goods{Id: 1000, Name: "9002的商品"}
@@ -35,5 +36,7 @@ func main() {
 		DialTimeout: 5 * time.Second,
 	})
 	client.RegisterService("goodsCenter", "127.0.0.1", 9002)
-	engine.Run(":9002")
+	if err := engine.Run(":9002"); err != nil {
+		log.Fatal(err)
+	}
 }
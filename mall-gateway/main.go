@@ -1,6 +1,7 @@
 package main
 
 import (
+	"log"
 	"net/http"
 	"time"
 
@@ -34,5 +35,7 @@ func main() {
 		Endpoints:   []string{"127.0.0.1:2379"},
 		DialTimeout: 5 * time.Second,
 	}
-	engine.Run(":80")
+	if err := engine.Run(":80"); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -18,29 +18,32 @@ import (
 
 func main() {
 	engine := gee.Default()
+	engine.AddMiddlewareFunc(gee.RequestID)
+	// 经过本机的mall-gateway转发
+	if err := engine.SetTrustedProxies([]string{"127.0.0.1", "::1"}); err != nil {
+		log.Fatal(err)
+	}
 	client := geeRpc.NewHttpClient()
 	client.RegisterHttpService("goodsService", &service.GoodsService{})
 	session := client.Session()
 
 	group := engine.Group("orders")
-	group.Get("/find", func(ctx *gee.Context) {
+	// 前端本地开发时跨域访问
+	group.AddMiddlewareFunc(gee.CORS(gee.CORSOptions{AllowOrigins: []string{"http://localhost:*"}}))
+	group.HandleWithError(http.MethodGet, "/find", func(ctx *gee.Context) error {
 		params := make(map[string]any)
 		params["id"] = 1000
 		params["name"] = "mi"
-		body, err := session.Do("goodsService", "Find").(*service.GoodsService).Find(params)
+		body, err := session.WithContext(ctx.R.Context()).Do("goodsService", "Find").(*service.GoodsService).Find(params)
 		if err != nil {
-			panic(err)
+			return err
 		}
 		log.Printf(string(body))
 		v := &model.Result{}
-		err = json.Unmarshal(body, v)
-		if err != nil {
-			panic(err)
-		}
-		err = ctx.JSON(http.StatusOK, v)
-		if err != nil {
-			panic(err)
+		if err := json.Unmarshal(body, v); err != nil {
+			return err
 		}
+		return ctx.JSON(http.StatusOK, v)
 	})
 
 	group.Get("/findGrpc", func(ctx *gee.Context) {
@@ -59,5 +62,7 @@ func main() {
 		ctx.JSON(http.StatusOK, rsp)
 	})
 
-	engine.Run(":9003")
+	if err := engine.Run(":9003"); err != nil {
+		log.Fatal(err)
+	}
 }